@@ -6,6 +6,7 @@ package main
 
 import (
 	"bytes"
+	"context"
 	"fmt"
 	"log"
 	"os"
@@ -16,6 +17,8 @@ import (
 	"sync"
 
 	"golang.org/x/mod/semver"
+
+	"aslevy.com/go-doc/internal/walk"
 )
 
 // A Dir describes a directory holding code by specifying
@@ -98,71 +101,44 @@ func (d *Dirs) walk(roots []Dir) {
 
 // bfsWalkRoot walks a single directory hierarchy in breadth-first lexical order.
 // Each Go source directory it finds is delivered on d.scan.
+//
+// Directories are read concurrently, but are delivered in the same order as a
+// sequential walk.
 func (d *Dirs) bfsWalkRoot(root Dir) {
 	root.dir = filepath.Clean(root.dir) // because filepath.Join will do it anyway
 
-	// this is the queue of directories to examine in this pass.
-	this := []string{}
-	// next is the queue of directories to examine in the next pass.
-	next := []string{root.dir}
-
-	for len(next) > 0 {
-		this, next = next, this[0:0]
-		for _, dir := range this {
-			fd, err := os.Open(dir)
-			if err != nil {
-				log.Print(err)
-				continue
-			}
-			entries, err := fd.Readdir(0)
-			fd.Close()
-			if err != nil {
-				log.Print(err)
-				continue
-			}
-			hasGoFiles := false
-			for _, entry := range entries {
-				name := entry.Name()
-				// For plain files, remember if this directory contains any .go
-				// source files, but ignore them otherwise.
-				if !entry.IsDir() {
-					if !hasGoFiles && strings.HasSuffix(name, ".go") {
-						hasGoFiles = true
-					}
-					continue
-				}
-				// Entry is a directory.
-
-				// The go tool ignores directories starting with ., _, or named "testdata".
-				if name[0] == '.' || name[0] == '_' || name == "testdata" {
-					continue
-				}
-				// When in a module, ignore vendor directories and stop at module boundaries.
-				if root.inModule {
-					if name == "vendor" {
-						continue
-					}
-					if fi, err := os.Stat(filepath.Join(dir, name, "go.mod")); err == nil && !fi.IsDir() {
-						continue
-					}
-				}
-				// Remember this (fully qualified) directory for the next pass.
-				next = append(next, filepath.Join(dir, name))
-			}
-			if hasGoFiles {
-				// It's a candidate.
-				importPath := root.importPath
-				if len(dir) > len(root.dir) {
-					if importPath != "" {
-						importPath += "/"
-					}
-					importPath += filepath.ToSlash(dir[len(root.dir)+1:])
+	type readDir struct {
+		walk.Dir
+		err error
+	}
+	read := func(_ context.Context, dir string) readDir {
+		wd, err := walk.ReadDir(dir, root.inModule)
+		return readDir{wd, err}
+	}
+	visit := func(dir string, res readDir) ([]string, error) {
+		if res.err != nil {
+			log.Print(res.err)
+			return nil, nil
+		}
+		if res.HasGoFiles {
+			// It's a candidate.
+			importPath := root.importPath
+			if len(dir) > len(root.dir) {
+				if importPath != "" {
+					importPath += "/"
 				}
-				d.scan <- Dir{importPath, dir, root.inModule}
+				importPath += filepath.ToSlash(dir[len(root.dir)+1:])
 			}
+			d.scan <- Dir{importPath, dir, root.inModule}
 		}
-
+		// Remember these (fully qualified) directories for the next pass.
+		next := make([]string, len(res.SubDirs))
+		for i, name := range res.SubDirs {
+			next[i] = filepath.Join(dir, name)
+		}
+		return next, nil
 	}
+	walk.BFS(context.Background(), walk.DefaultWorkers, root.dir, read, visit)
 }
 
 var testGOPATH = false // force GOPATH use for testing
@@ -199,6 +175,10 @@ func findCodeRoots() []Dir {
 				Dir{importPath: "cmd", dir: filepath.Join(buildCtx.GOROOT, "src", "cmd"), inModule: true})
 		}
 
+		if gowork := goWork(); gowork != "" {
+			return append(list, workspaceCodeRoots(gowork)...)
+		}
+
 		if gomod == os.DevNull {
 			// Modules are enabled, but the working directory is outside any module.
 			// We can still access std, cmd, and packages specified as source files
@@ -1,7 +1,19 @@
 package main
 
 import (
+	"bytes"
+	"context"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"sync"
+
+	"golang.org/x/mod/modfile"
+
+	"aslevy.com/go-doc/internal/dlog"
 	"aslevy.com/go-doc/internal/godoc"
+	"aslevy.com/go-doc/internal/vendored"
 )
 
 var xdirs godoc.Dirs = dirs.PackageDirs()
@@ -23,3 +35,236 @@ func (d *PackageDirs) FilterExact(string) error   { return godoc.ErrFilterNotSup
 func (d *PackageDirs) FilterPartial(string) error { return godoc.ErrFilterNotSupported }
 
 func (dirs *Dirs) registerPackage(importPath, dir string) { dirs.scan <- Dir{importPath, dir, true} }
+
+// goWork returns the path to the go.work file if in workspace mode, otherwise
+// the empty string.
+var goWork = sync.OnceValue(findGoWork)
+
+func findGoWork() string {
+	if testGOPATH {
+		return ""
+	}
+	stdout, err := exec.Command(goCmd(), "env", "GOWORK").Output()
+	if err != nil {
+		dlog.Printf("failed to run `go env GOWORK`: %v", err)
+		return ""
+	}
+	gowork := string(bytes.TrimSpace(stdout))
+	if gowork == "off" {
+		return ""
+	}
+	return gowork
+}
+
+// mainGoMod returns the parsed go.mod file of the main module, or nil if there
+// is none.
+var mainGoMod = sync.OnceValue(parseMainGoMod)
+
+func parseMainGoMod() *modfile.File {
+	if testGOPATH {
+		return nil
+	}
+	stdout, err := exec.Command(goCmd(), "env", "GOMOD").Output()
+	if err != nil {
+		dlog.Printf("failed to run `go env GOMOD`: %v", err)
+		return nil
+	}
+	gomod := string(bytes.TrimSpace(stdout))
+	if gomod == "" || gomod == os.DevNull {
+		return nil
+	}
+	data, err := os.ReadFile(gomod)
+	if err != nil {
+		dlog.Printf("failed to read %s: %v", gomod, err)
+		return nil
+	}
+	f, err := modfile.Parse(gomod, data, nil)
+	if err != nil {
+		dlog.Printf("failed to parse %s: %v", gomod, err)
+		return nil
+	}
+	return f
+}
+
+// resetCodeRoots discards the cached code roots, go.work file and its modules,
+// and go.mod file of the main module, so that they are found again once the
+// module files change.
+// See packageIndex.
+func resetCodeRoots() {
+	codeRootsCache.once = sync.Once{}
+	codeRootsCache.roots = nil
+	goWork = sync.OnceValue(findGoWork)
+	mainGoMod = sync.OnceValue(parseMainGoMod)
+	workspaceModules = sync.OnceValue(findWorkspaceModules)
+}
+
+// workspaceCodeRoots returns the code roots of the workspace defined by the
+// gowork file.
+//
+// Every module in a use directive is a local module root, followed by the
+// roots of all other modules listed by `go list -m all`, which runs in
+// workspace mode.
+func workspaceCodeRoots(gowork string) []Dir {
+	list, ok := workspaceUses(gowork)
+	if !ok {
+		return nil
+	}
+	seen := make(map[string]bool, len(list))
+	for _, root := range list {
+		seen[root.dir] = true
+	}
+
+	cmd := exec.Command(goCmd(), "list", "-m", "-f={{.Path}}\t{{.Dir}}", "all")
+	cmd.Dir = filepath.Dir(gowork)
+	cmd.Stderr = os.Stderr
+	out, _ := cmd.Output()
+	for _, line := range strings.Split(string(out), "\n") {
+		path, dir, _ := strings.Cut(line, "\t")
+		if dir != "" && !seen[dir] {
+			list = append(list, Dir{importPath: path, dir: dir, inModule: true})
+		}
+	}
+	return list
+}
+
+// workspaceUses returns the roots of the modules in the use directives of the
+// gowork file, in the order they are listed, and whether it could be read.
+func workspaceUses(gowork string) ([]Dir, bool) {
+	data, err := os.ReadFile(gowork)
+	if err != nil {
+		dlog.Printf("failed to read %s: %v", gowork, err)
+		return nil, false
+	}
+	work, err := modfile.ParseWork(gowork, data, nil)
+	if err != nil {
+		dlog.Printf("failed to parse %s: %v", gowork, err)
+		return nil, false
+	}
+
+	var list []Dir
+	for _, use := range work.Use {
+		dir := use.Path
+		if !filepath.IsAbs(dir) {
+			dir = filepath.Join(filepath.Dir(gowork), dir)
+		}
+		dir = filepath.Clean(dir)
+		data, err := os.ReadFile(filepath.Join(dir, "go.mod"))
+		if err != nil {
+			dlog.Printf("failed to read go.mod of workspace module: %v", err)
+			continue
+		}
+		list = append(list, Dir{importPath: modfile.ModulePath(data), dir: dir, inModule: true})
+	}
+	return list, true
+}
+
+// workspaceModules returns the paths of the modules in the use directives of
+// the go.work file, if in workspace mode.
+var workspaceModules = sync.OnceValue(findWorkspaceModules)
+
+func findWorkspaceModules() map[string]bool {
+	gowork := goWork()
+	if gowork == "" {
+		return nil
+	}
+	uses, _ := workspaceUses(gowork)
+	paths := make(map[string]bool, len(uses))
+	for _, use := range uses {
+		paths[use.importPath] = true
+	}
+	return paths
+}
+
+// localCodeRoots returns the roots of the main module, or of every module in
+// the workspace. These are the code roots which are not in GOROOT, vendor, or
+// the module cache.
+func localCodeRoots() []godoc.PackageDir {
+	var roots []godoc.PackageDir
+	for _, root := range codeRoots() {
+		switch {
+		case !root.inModule, root.importPath == "", root.importPath == "cmd",
+			strings.Contains(filepath.Base(root.dir), "@"):
+			continue
+		}
+		roots = append(roots, godoc.NewPackageDir(root.importPath, root.dir))
+	}
+	return roots
+}
+
+// dependencyCodeRoots returns the roots of the modules which the local modules
+// require, in the module cache, or the vendor directory of the main module.
+func dependencyCodeRoots() []godoc.PackageDir {
+	var roots []godoc.PackageDir
+	for _, root := range codeRoots() {
+		switch {
+		case root.inModule && strings.Contains(filepath.Base(root.dir), "@"),
+			!root.inModule && filepath.Base(root.dir) == "vendor":
+			roots = append(roots, godoc.NewPackageDir(root.importPath, root.dir))
+		}
+	}
+	return roots
+}
+
+// Module returns the module which holds the package in dir, as far as it can
+// be told from the code roots, which is all that is known without an index.
+func (d *PackageDirs) Module(dir string) (godoc.Module, bool) {
+	dir = filepath.Clean(dir)
+	var root Dir
+	for _, r := range codeRoots() {
+		if dir != r.dir && !strings.HasPrefix(dir, r.dir+string(filepath.Separator)) {
+			continue
+		}
+		// Modules may be nested, e.g. cmd in std, so the closest root
+		// wins.
+		if len(r.dir) > len(root.dir) {
+			root = r
+		}
+	}
+	goroot := filepath.Join(buildCtx.GOROOT, "src")
+	switch {
+	case root.dir == "":
+		return godoc.Module{}, false
+	case root.dir == goroot:
+		return godoc.Module{Path: "std", Class: "stdlib"}, true
+	case root.dir == filepath.Join(goroot, "cmd"):
+		return godoc.Module{Path: "cmd", Class: "stdlib"}, true
+	case !root.inModule && filepath.Base(root.dir) == "vendor":
+		return vendoredModule(root.dir, dir)
+	case !root.inModule:
+		// GOPATH
+		return godoc.Module{}, false
+	}
+	mod := godoc.Module{Path: root.importPath, Class: "local"}
+	if _, version, ok := strings.Cut(filepath.Base(root.dir), "@"); ok {
+		mod.Version, mod.Class = version, "required"
+	}
+	return mod, true
+}
+
+// vendoredModule returns the module listed in the modules.txt file of vendorDir
+// which holds the package in dir.
+func vendoredModule(vendorDir, dir string) (godoc.Module, bool) {
+	rel, err := filepath.Rel(vendorDir, dir)
+	if err != nil {
+		return godoc.Module{}, false
+	}
+	importPath := filepath.ToSlash(rel)
+	modPkgs, err := vendored.ParseModulePackages(context.Background(), vendorDir)
+	if err != nil {
+		dlog.Printf("failed to parse vendored modules: %v", err)
+		return godoc.Module{}, false
+	}
+	for mod, pkgs := range modPkgs {
+		for _, pkg := range pkgs {
+			if pkg.ImportPath == importPath {
+				return godoc.Module{
+					Path:    mod.Path,
+					Version: mod.Version,
+					Replace: mod.Replace(),
+					Class:   "vendored",
+				}, true
+			}
+		}
+	}
+	return godoc.Module{}, false
+}
@@ -0,0 +1,47 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestWorkspaceCodeRoots(t *testing.T) {
+	t.Setenv("GOFLAGS", "")
+	t.Setenv("GOTOOLCHAIN", "local")
+	work := t.TempDir()
+	writeFile := func(name, data string) {
+		t.Helper()
+		path := filepath.Join(work, filepath.FromSlash(name))
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(data), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	writeFile("b/go.mod", "module example.com/b\n\ngo 1.21\n")
+	writeFile("a/go.mod", "module example.com/a\n\ngo 1.21\n")
+	// The nested module is not used by the workspace, so it is not a code
+	// root, even though it is within one.
+	writeFile("a/nested/go.mod", "module example.com/a/nested\n\ngo 1.21\n")
+	// The modules of the use directives come first, in the order they are
+	// listed, rather than sorted.
+	writeFile("go.work", "go 1.21\n\nuse (\n\t./b\n\t./a\n)\n")
+	gowork := filepath.Join(work, "go.work")
+	t.Setenv("GOWORK", gowork)
+
+	want := []Dir{
+		{importPath: "example.com/b", dir: filepath.Join(work, "b"), inModule: true},
+		{importPath: "example.com/a", dir: filepath.Join(work, "a"), inModule: true},
+	}
+	got := workspaceCodeRoots(gowork)
+	if len(got) != len(want) {
+		t.Fatalf("workspaceCodeRoots(%q) = %+v, want %+v", gowork, got, want)
+	}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Errorf("workspaceCodeRoots(%q)[%d] = %+v, want %+v", gowork, i, got[i], want[i])
+		}
+	}
+}
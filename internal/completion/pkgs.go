@@ -8,6 +8,8 @@ import (
 	"path/filepath"
 	"strings"
 
+	"golang.org/x/mod/module"
+
 	"aslevy.com/go-doc/internal/dlog"
 	"aslevy.com/go-doc/internal/godoc"
 )
@@ -52,19 +54,28 @@ func (c Completer) completePackageImportPaths(partial string) (matched bool) {
 		}
 
 		match := matchPackage(importPathSegments, countGlobs(partials...), partials...)
+		if match == "" {
+			match = c.matchPackageName(dir, partials...)
+		}
 		if match == "" {
 			// Not a match.
 			continue
 		}
 		dlog.Printf("found package import path %q", dir.ImportPath)
 
-		desc, ok := describePackage(dir.Dir)
+		desc, ok := c.describePackage(dir.Dir)
 		if !ok {
 			// Not a real package, so we can remove its unique
 			// short path.
 			delete(shortPaths, shortPath)
 			continue
 		}
+		if version, ok := c.moduleVersion(dir.Dir); ok {
+			desc += " (" + version + ")"
+		}
+		if c.notRequired(dir.Dir) {
+			desc += " (not required)"
+		}
 
 		matched = true
 
@@ -201,44 +212,78 @@ func (c Completer) completePackageFilePaths(partial string) (matched bool) {
 	return
 }
 
+// matchPackageName is like matchPackage, but matches the import path of dir
+// with its last element, after dropping any major version suffix, replaced by
+// the name of the package, if known. So yaml matches gopkg.in/yaml.v3.
+func (c Completer) matchPackageName(dir godoc.PackageDir, partials ...string) string {
+	dirs, ok := c.dirs.(godoc.PackageNameDirs)
+	if !ok {
+		return ""
+	}
+	name := dirs.PackageName(dir.Dir)
+	if name == "" || name == "main" {
+		return ""
+	}
+	importPath := dir.ImportPath
+	if prefix, pathMajor, _ := module.SplitPathVersion(importPath); pathMajor != "" {
+		importPath = prefix
+	}
+	segments := strings.Split(importPath, "/")
+	segments[len(segments)-1] = name
+	return matchPackage(segments, countGlobs(partials...), partials...)
+}
+
+// notRequired reports whether the package in dir belongs to a module in the
+// module cache which the main module does not require.
+func (c Completer) notRequired(dir string) bool {
+	dirs, ok := c.dirs.(godoc.NotRequiredDirs)
+	if !ok {
+		return false
+	}
+	_, notRequired := dirs.NotRequired(dir)
+	return notRequired
+}
+
+// moduleVersion returns the version of the module of the package in dir, if
+// it is known by the Dirs.
+func (c Completer) moduleVersion(dir string) (string, bool) {
+	dirs, ok := c.dirs.(godoc.ModuleVersionDirs)
+	if !ok {
+		return "", false
+	}
+	return dirs.ModuleVersion(dir)
+}
+
+// describePackage returns the description of the package in dir from the
+// summary known by the Dirs, if any, so that its files need not be read.
+func (c Completer) describePackage(dir string) (string, bool) {
+	dirs, ok := c.dirs.(godoc.PackageSummaryDirs)
+	if !ok {
+		return describePackage(dir)
+	}
+	summary, ok := dirs.PackageSummary(dir)
+	if !ok {
+		return describePackage(dir)
+	}
+	return summaryDescription(summary), true
+}
+
 func describePackage(packageDir string) (string, bool) {
 	pkg, err := build.ImportDir(packageDir, build.ImportComment)
 	if err != nil {
 		dlog.Printf("failed to import %q: %v", packageDir, err)
 		return "", false
 	}
-	docs := firstSentence(pkg.Doc)
-	docs = trimPackagePrefix(docs, pkg.Name)
-	if docs == "" {
-		docs = "Package " + pkg.Name
-	}
-	return docs, true
-}
-func firstSentence(docs string) string {
-	// Get the first paragraph.
-	docs, _, _ = strings.Cut(docs, "\n\n")
-	// Join all lines.
-	docs = strings.ReplaceAll(docs, "\n", " ")
-	// Get the first sentence.
-	docs, _, found := strings.Cut(docs, ". ")
-	if found {
-		return docs
-	}
-	// The first paragraph may have been a single sentence with a newline
-	// instead of a space after the period. Remove any trailing period for
-	// consistency.
-	docs = strings.TrimSuffix(docs, ".")
-	return docs
+	return summaryDescription(godoc.PackageSummary{
+		Name:     pkg.Name,
+		Synopsis: godoc.Synopsis(pkg.Name, pkg.Doc),
+	}), true
 }
-func trimPackagePrefix(docs, pkgName string) string {
-	if d := strings.TrimPrefix(docs, "Package "); d != docs {
-		docs = d
-	} else {
-		docs = strings.TrimPrefix(docs, "package ")
+func summaryDescription(summary godoc.PackageSummary) string {
+	if summary.Synopsis != "" {
+		return summary.Synopsis
 	}
-	docs = strings.TrimPrefix(docs, pkgName)
-	docs = strings.TrimPrefix(docs, " ")
-	return docs
+	return "Package " + summary.Name
 }
 
 type ShortImportPaths map[string]struct{}
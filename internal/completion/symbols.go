@@ -173,7 +173,7 @@ func (c Completer) suggestIfMatchPrefix(pkg godoc.PackageInfo, partial, name, do
 	}
 	display := pkg.OneLineNode(node, olnOpts...)
 
-	docs = firstSentence(docs)
+	docs = godoc.FirstSentence(docs)
 	docs = strings.TrimPrefix(docs, name+" ")
 
 	c.suggest(NewMatch(name,
@@ -0,0 +1,309 @@
+// Package daemon serves go doc requests from a long-lived process over a Unix
+// socket.
+//
+// Every tab press during completion runs a fresh go-doc process, which must
+// find the code roots, open the index and parse packages all over again.
+// A daemon started with -serve keeps that state warm between requests for the
+// module or workspace it was started in, and stops itself once it has been
+// idle for a while.
+//
+// A go-doc process sends its request to the daemon with Send if one is
+// running for the current project, and otherwise handles the request itself.
+package daemon
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	_dlog "aslevy.com/go-doc/internal/dlog"
+)
+
+const (
+	// EnvVar controls the use of the daemon. If "off", requests are never
+	// sent to a daemon. If "auto", a daemon is started in the background
+	// when none is running. Otherwise requests are sent to a daemon only
+	// if one is already running.
+	EnvVar = "GODOC_DAEMON"
+
+	DefaultIdleTimeout = 30 * time.Minute
+
+	// envPrefix is the prefix of the environment variables of the client
+	// which are sent with each request.
+	envPrefix = "GODOC_"
+)
+
+var (
+	dlog = _dlog.Child("daemon")
+
+	// Requested is true if go-doc should serve requests instead of
+	// handling its arguments.
+	Requested bool
+	// IdleTimeout is how long the daemon waits for a request before
+	// stopping.
+	IdleTimeout = DefaultIdleTimeout
+)
+
+func AddFlags(fs *flag.FlagSet) {
+	fs.Var(dlog.EnableFlag(), "debug-daemon", "enable debug logging for daemon")
+	fs.BoolVar(&Requested, "serve", false, "serve completions and docs for the current module over a Unix socket until idle")
+	fs.DurationVar(&IdleTimeout, "serve-idle", DefaultIdleTimeout, "stop serving after being idle for this duration")
+}
+
+// Mode returns the value of EnvVar.
+func Mode() string { return strings.TrimSpace(os.Getenv(EnvVar)) }
+
+// Request is a go doc invocation sent to the daemon.
+type Request struct {
+	// Dir is the working directory of the client.
+	Dir string
+	// Args are the arguments of the client, excluding the program name.
+	Args []string
+	// Env holds the GODOC_ environment variables of the client.
+	Env []string
+}
+
+// NewRequest returns a Request for args from the current process.
+func NewRequest(args []string) (Request, error) {
+	wd, err := os.Getwd()
+	if err != nil {
+		return Request{}, err
+	}
+	return Request{Dir: wd, Args: args, Env: goDocEnv(os.Environ())}, nil
+}
+
+// Response is the result of a Request.
+type Response struct {
+	// Output is everything the request wrote to stdout.
+	Output []byte
+	// Err is the error returned by the request, if any.
+	Err string
+}
+
+// Handler handles a Request, writing its output to w.
+type Handler func(w io.Writer, req Request) error
+
+// ProjectRoot returns the root directory of the workspace or module containing
+// dir, which is the directory with the go.work or go.mod file, or the empty
+// string if there is none.
+//
+// Unlike `go env GOMOD`, this does not run the go command, so that it is cheap
+// enough to run before every request.
+func ProjectRoot(dir string) string {
+	switch gowork := os.Getenv("GOWORK"); gowork {
+	case "off":
+	case "", "auto":
+		if root := findUp(dir, "go.work"); root != "" {
+			return root
+		}
+	default:
+		return filepath.Dir(gowork)
+	}
+	return findUp(dir, "go.mod")
+}
+func findUp(dir, name string) string {
+	for {
+		if _, err := os.Stat(filepath.Join(dir, name)); err == nil {
+			return dir
+		}
+		parent := filepath.Dir(dir)
+		if parent == dir {
+			return ""
+		}
+		dir = parent
+	}
+}
+
+// SocketPath returns the path to the socket of the daemon for the project
+// rooted at projectRoot.
+//
+// Sockets are kept in a per user directory, since the length of socket paths
+// is limited: in $XDG_RUNTIME_DIR if it is set, otherwise in the temp dir.
+func SocketPath(projectRoot string) string {
+	sum := sha256.Sum256([]byte(projectRoot))
+	return filepath.Join(socketDir(), fmt.Sprintf("%x.sock", sum[:8]))
+}
+func socketDir() string {
+	if runtimeDir := os.Getenv("XDG_RUNTIME_DIR"); runtimeDir != "" {
+		return filepath.Join(runtimeDir, "go-doc")
+	}
+	return filepath.Join(os.TempDir(), fmt.Sprintf("go-doc-%d", os.Getuid()))
+}
+
+// ErrRunning is returned by Listen if a daemon is already serving the socket.
+var ErrRunning = errors.New("daemon is already running")
+
+// Listen listens on the socket at path, removing the socket of any daemon
+// which is no longer running.
+func Listen(path string) (*net.UnixListener, error) {
+	dir := filepath.Dir(path)
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		return nil, err
+	}
+	if err := checkSocketDir(dir); err != nil {
+		return nil, err
+	}
+	if conn, err := net.DialTimeout("unix", path, time.Second); err == nil {
+		conn.Close()
+		return nil, ErrRunning
+	}
+	if err := os.Remove(path); err != nil && !os.IsNotExist(err) {
+		return nil, err
+	}
+	return net.ListenUnix("unix", &net.UnixAddr{Name: path, Net: "unix"})
+}
+
+// checkSocketDir returns an error unless dir is a directory, not a symlink,
+// which is owned by the current user and only accessible by them.
+//
+// The name of the socket directory is predictable, so another user could
+// create it first, to have clients send their requests to a daemon of their
+// own, or to have Listen replace sockets in a directory they control.
+func checkSocketDir(dir string) error {
+	info, err := os.Lstat(dir)
+	if err != nil {
+		return err
+	}
+	if !info.IsDir() || !ownedByUser(info) || info.Mode().Perm() != 0700 {
+		return fmt.Errorf("refusing to use socket directory %q: it must be a directory owned by the current user with mode 0700, not %v", dir, info.Mode())
+	}
+	return nil
+}
+
+// requestTimeout bounds how long a single request may take to be read,
+// handled and answered.
+const requestTimeout = time.Minute
+
+// Serve handles the requests on ln one at a time with h, until ctx is done or
+// no request arrives within idle. The socket is removed when Serve returns.
+//
+// Requests are handled one at a time since the handler typically relies on
+// global state, like the working directory and flags.
+func Serve(ctx context.Context, ln *net.UnixListener, idle time.Duration, h Handler) error {
+	defer ln.Close()
+	done := make(chan struct{})
+	defer close(done)
+	go func() {
+		select {
+		case <-ctx.Done():
+			ln.SetDeadline(time.Now())
+		case <-done:
+		}
+	}()
+
+	dlog.Printf("serving on %q", ln.Addr())
+	for {
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+		if err := ln.SetDeadline(time.Now().Add(idle)); err != nil {
+			return err
+		}
+		conn, err := ln.AcceptUnix()
+		if err != nil {
+			if errors.Is(err, os.ErrDeadlineExceeded) {
+				dlog.Printf("stopping")
+				return ctx.Err()
+			}
+			return err
+		}
+		if err := handle(conn, h); err != nil {
+			dlog.Printf("failed to handle request: %v", err)
+		}
+	}
+}
+
+func handle(conn net.Conn, h Handler) error {
+	defer conn.Close()
+	if err := conn.SetDeadline(time.Now().Add(requestTimeout)); err != nil {
+		return err
+	}
+
+	var req Request
+	if err := json.NewDecoder(conn).Decode(&req); err != nil {
+		return fmt.Errorf("failed to decode request: %w", err)
+	}
+	dlog.Printf("request: %+v", req)
+
+	var res Response
+	var out bytes.Buffer
+	if err := handleRequest(&out, req, h); err != nil {
+		res.Err = err.Error()
+	}
+	res.Output = out.Bytes()
+	return json.NewEncoder(conn).Encode(res)
+}
+func handleRequest(w io.Writer, req Request, h Handler) (err error) {
+	defer func() {
+		if e := recover(); e != nil {
+			err = fmt.Errorf("panic: %v", e)
+		}
+	}()
+	defer setEnv(req.Env)()
+	return h(w, req)
+}
+
+// setEnv replaces the GODOC_ environment variables with those of env, and
+// returns a func which restores them.
+func setEnv(env []string) (restore func()) {
+	prev := goDocEnv(os.Environ())
+	replace := func(from, to []string) {
+		for _, kv := range from {
+			k, _, _ := strings.Cut(kv, "=")
+			os.Unsetenv(k)
+		}
+		for _, kv := range to {
+			k, v, _ := strings.Cut(kv, "=")
+			os.Setenv(k, v)
+		}
+	}
+	replace(prev, env)
+	return func() { replace(env, prev) }
+}
+func goDocEnv(environ []string) []string {
+	var env []string
+	for _, kv := range environ {
+		if strings.HasPrefix(kv, envPrefix) {
+			env = append(env, kv)
+		}
+	}
+	return env
+}
+
+// Send sends req to the daemon listening on the socket at path and returns
+// its response.
+//
+// An error is returned if no daemon is running, or if it fails to respond,
+// in which case the client should handle the request itself.
+func Send(path string, req Request) (Response, error) {
+	if err := checkSocketDir(filepath.Dir(path)); err != nil {
+		return Response{}, err
+	}
+	conn, err := net.DialTimeout("unix", path, 100*time.Millisecond)
+	if err != nil {
+		return Response{}, err
+	}
+	defer conn.Close()
+	if err := conn.SetDeadline(time.Now().Add(requestTimeout)); err != nil {
+		return Response{}, err
+	}
+
+	if err := json.NewEncoder(conn).Encode(req); err != nil {
+		return Response{}, fmt.Errorf("failed to send request: %w", err)
+	}
+	var res Response
+	if err := json.NewDecoder(conn).Decode(&res); err != nil {
+		return Response{}, fmt.Errorf("failed to receive response: %w", err)
+	}
+	return res, nil
+}
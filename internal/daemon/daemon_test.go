@@ -0,0 +1,122 @@
+package daemon
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestServe(t *testing.T) {
+	require := require.New(t)
+	path := filepath.Join(t.TempDir(), "go-doc", "test.sock")
+
+	ln, err := Listen(path)
+	require.NoError(err)
+
+	t.Setenv("GODOC_TEST", "daemon")
+	done := make(chan error)
+	go func() {
+		done <- Serve(context.Background(), ln, 500*time.Millisecond, func(w io.Writer, req Request) error {
+			if len(req.Args) == 0 {
+				return errors.New("no args")
+			}
+			if req.Args[0] == "panic" {
+				panic("at the disco")
+			}
+			_, err := fmt.Fprintf(w, "%s %s %s", req.Dir, strings.Join(req.Args, " "), os.Getenv("GODOC_TEST"))
+			return err
+		})
+	}()
+
+	_, err = Listen(path)
+	require.ErrorIs(err, ErrRunning)
+
+	res, err := Send(path, Request{Dir: "/dir", Args: []string{"a", "b"}, Env: []string{"GODOC_TEST=client"}})
+	require.NoError(err)
+	require.Empty(res.Err)
+	require.Equal("/dir a b client", string(res.Output))
+	require.Equal("daemon", os.Getenv("GODOC_TEST"), "the environment of the daemon is restored")
+
+	res, err = Send(path, Request{})
+	require.NoError(err)
+	require.Equal("no args", res.Err)
+
+	res, err = Send(path, Request{Args: []string{"panic"}})
+	require.NoError(err)
+	require.Equal("panic: at the disco", res.Err)
+
+	// The daemon stops once idle.
+	require.NoError(<-done)
+	_, err = Send(path, Request{})
+	require.Error(err)
+
+	// The socket of a stopped daemon may be reused.
+	ln, err = Listen(path)
+	require.NoError(err)
+	require.NoError(ln.Close())
+}
+
+func TestSocketDir(t *testing.T) {
+	require := require.New(t)
+	runtimeDir := t.TempDir()
+	t.Setenv("XDG_RUNTIME_DIR", runtimeDir)
+	path := SocketPath("/project")
+	require.Equal(filepath.Join(runtimeDir, "go-doc"), filepath.Dir(path))
+
+	ln, err := Listen(path)
+	require.NoError(err)
+	require.NoError(ln.Close())
+
+	// A socket directory which others may access is not used.
+	require.NoError(os.Chmod(filepath.Dir(path), 0755))
+	_, err = Listen(path)
+	require.ErrorContains(err, "refusing to use socket directory")
+	_, err = Send(path, Request{})
+	require.ErrorContains(err, "refusing to use socket directory")
+
+	// Nor is a symlink to a directory.
+	link := filepath.Join(t.TempDir(), "link")
+	require.NoError(os.Chmod(filepath.Dir(path), 0700))
+	require.NoError(os.Symlink(filepath.Dir(path), link))
+	_, err = Listen(filepath.Join(link, "test.sock"))
+	require.ErrorContains(err, "refusing to use socket directory")
+}
+
+func TestServe_cancel(t *testing.T) {
+	require := require.New(t)
+	ln, err := Listen(filepath.Join(t.TempDir(), "go-doc", "test.sock"))
+	require.NoError(err)
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error)
+	go func() {
+		done <- Serve(ctx, ln, time.Minute, func(io.Writer, Request) error { return nil })
+	}()
+	cancel()
+	require.ErrorIs(<-done, context.Canceled)
+}
+
+func TestProjectRoot(t *testing.T) {
+	require := require.New(t)
+	dir := t.TempDir()
+	mod := filepath.Join(dir, "mod")
+	sub := filepath.Join(mod, "sub")
+	require.NoError(os.MkdirAll(sub, 0755))
+	require.NoError(os.WriteFile(filepath.Join(mod, "go.mod"), []byte("module example.com/mod\n"), 0644))
+
+	t.Setenv("GOWORK", "")
+	require.Equal(mod, ProjectRoot(sub))
+
+	require.NoError(os.WriteFile(filepath.Join(dir, "go.work"), []byte("use ./mod\n"), 0644))
+	require.Equal(dir, ProjectRoot(sub))
+
+	t.Setenv("GOWORK", "off")
+	require.Equal(mod, ProjectRoot(sub))
+}
@@ -9,13 +9,17 @@ import (
 	"strings"
 
 	"aslevy.com/go-doc/internal/completion"
+	"aslevy.com/go-doc/internal/daemon"
 	"aslevy.com/go-doc/internal/dlog"
 	"aslevy.com/go-doc/internal/godoc"
+	"aslevy.com/go-doc/internal/implements"
 	"aslevy.com/go-doc/internal/index"
 	"aslevy.com/go-doc/internal/install"
 	"aslevy.com/go-doc/internal/open"
 	"aslevy.com/go-doc/internal/outfmt"
 	"aslevy.com/go-doc/internal/pager"
+	"aslevy.com/go-doc/internal/refs"
+	"aslevy.com/go-doc/internal/suggest"
 )
 
 // addAllFlags to fs.
@@ -28,6 +32,10 @@ func addAllFlags(fs *flag.FlagSet) {
 	open.AddFlags(fs)
 	index.AddFlags(fs)
 	outfmt.AddFlags(fs)
+	daemon.AddFlags(fs)
+	suggest.AddFlags(fs)
+	refs.AddFlags(fs)
+	implements.AddFlags(fs)
 }
 
 // Parse is like [flag.FlagSet.Parse], but it adds all flags defined in this
@@ -64,6 +72,8 @@ func addAllFlags(fs *flag.FlagSet) {
 //
 //syntax:text
 func Parse(fs *flag.FlagSet, args ...string) error {
+	// Parse may be called for each request served by a daemon.
+	completion.Requested = false
 	if len(args) > 0 && args[0] == "-complete" {
 		args = args[1:]
 		completion.Requested = true
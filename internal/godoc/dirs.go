@@ -29,4 +29,76 @@ type Dirs interface {
 	FilterPartial(path string) error
 }
 
+// NotRequiredDirs is implemented by Dirs which may list the packages of
+// modules in the module cache which the main module does not require.
+type NotRequiredDirs interface {
+	// NotRequired reports whether the package in dir belongs to a module
+	// which is not required, and if so, returns its import path.
+	NotRequired(dir string) (importPath string, notRequired bool)
+}
+
+// ModuleVersionDirs is implemented by Dirs which know the version of the
+// module of each package they return.
+type ModuleVersionDirs interface {
+	// ModuleVersion returns the version of the module which holds the
+	// package in dir, followed by its replacement, if any, as in
+	// "v1.2.3 => ../fork". ok is false if the module is not versioned,
+	// such as the stdlib or a local module.
+	ModuleVersion(dir string) (version string, ok bool)
+}
+
+// Module describes the module which holds a package.
+type Module struct {
+	// Path is the module path, which is "std" or "cmd" for the stdlib.
+	Path string
+	// Version is the version of the module, or of Go for the stdlib, if
+	// known.
+	Version string
+	// Replace is the target of the replace directive of the module, if
+	// any, as in go.mod, e.g. "../fork" or "example.com/fork v1.5.0".
+	Replace string
+	// Class is one of "stdlib", "local", "required", "not required" or
+	// "vendored".
+	Class string
+}
+
+// ModuleDirs is implemented by Dirs which know the module of each package
+// they return.
+type ModuleDirs interface {
+	// Module returns the module which holds the package in dir, and
+	// whether it is known. Packages in GOPATH have no module.
+	Module(dir string) (Module, bool)
+}
+
+// PackageNameDirs is implemented by Dirs which know the name declared by the
+// package clause of each package they return, which may differ from the last
+// element of its import path.
+type PackageNameDirs interface {
+	// PackageName returns the name of the package in dir, or the empty
+	// string if it is not known.
+	PackageName(dir string) string
+}
+
+// PackageSummary describes a package well enough to suggest it for completion
+// without reading its files.
+type PackageSummary struct {
+	// Name is the name declared by the package clause.
+	Name string
+	// Synopsis is the first sentence of the package doc comment, without
+	// any "Package name" prefix. See Synopsis.
+	Synopsis string
+	// IsCommand is true if the package is a command, i.e. package main.
+	IsCommand bool
+	// NumFiles is the number of Go files in the package, excluding tests.
+	NumFiles int
+}
+
+// PackageSummaryDirs is implemented by Dirs which know the PackageSummary of
+// each package they return.
+type PackageSummaryDirs interface {
+	// PackageSummary returns the summary of the package in dir, and
+	// whether it is known.
+	PackageSummary(dir string) (PackageSummary, bool)
+}
+
 var ErrFilterNotSupported = errors.New("filter not supported")
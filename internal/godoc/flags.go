@@ -6,10 +6,12 @@ var (
 	NoImports  bool
 	ShowStdlib bool
 	NoLocation bool
+	ShowModule bool
 )
 
 func AddFlags(fs *flag.FlagSet) {
 	fs.BoolVar(&NoImports, "imports-off", false, "do not show the imports for referenced packages")
 	fs.BoolVar(&ShowStdlib, "imports-stdlib", false, "show imports for referenced stdlib packages")
 	fs.BoolVar(&NoLocation, "location-off", false, "do not show symbol file location i.e. // /path/to/circle.go +314")
+	fs.BoolVar(&ShowModule, "module", false, "show the module of the package, its version and class above the package clause")
 }
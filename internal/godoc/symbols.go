@@ -0,0 +1,46 @@
+package godoc
+
+import "context"
+
+// SymbolKind identifies the kind of declaration a Symbol was found in.
+//
+// SymbolKind is a type alias purely for documentation purposes.
+type SymbolKind = string
+
+const (
+	SymbolConst  SymbolKind = "const"
+	SymbolVar    SymbolKind = "var"
+	SymbolFunc   SymbolKind = "func"
+	SymbolType   SymbolKind = "type"
+	SymbolMethod SymbolKind = "method"
+	SymbolField  SymbolKind = "field"
+)
+
+// Symbol describes an exported symbol declared in a package.
+type Symbol struct {
+	Kind SymbolKind
+	// Type is the name of the receiver of a method, or the type which
+	// declares a field or interface method. It is empty for all other
+	// kinds.
+	Type string
+	Name string
+	// Summary is the one-line summary of the declaration as rendered by
+	// PackageInfo.OneLineNode.
+	Summary string
+	// Doc is the doc comment of the declaration, if any.
+	Doc string
+}
+
+// PackageSymbols is the name, doc comment and exported symbols of a package.
+type PackageSymbols struct {
+	Name    string
+	Doc     string
+	Symbols []Symbol
+}
+
+// SymbolLoader loads the doc comment and exported symbols of the package in
+// the given directory.
+//
+// This allows the index to record symbols using the same rendering as go doc
+// without depending on the main package.
+type SymbolLoader func(ctx context.Context, pkg PackageDir) (PackageSymbols, error)
@@ -0,0 +1,38 @@
+package godoc
+
+import "strings"
+
+// Synopsis returns the first sentence of doc, the doc comment of the package
+// with name, without any leading "Package name".
+func Synopsis(name, doc string) string {
+	return trimPackagePrefix(FirstSentence(doc), name)
+}
+
+// FirstSentence returns the first sentence of the first paragraph of docs,
+// joined onto one line, without any trailing period.
+func FirstSentence(docs string) string {
+	// Get the first paragraph.
+	docs, _, _ = strings.Cut(docs, "\n\n")
+	// Join all lines.
+	docs = strings.ReplaceAll(docs, "\n", " ")
+	// Get the first sentence.
+	docs, _, found := strings.Cut(docs, ". ")
+	if found {
+		return docs
+	}
+	// The first paragraph may have been a single sentence with a newline
+	// instead of a space after the period. Remove any trailing period for
+	// consistency.
+	docs = strings.TrimSuffix(docs, ".")
+	return docs
+}
+func trimPackagePrefix(docs, pkgName string) string {
+	if d := strings.TrimPrefix(docs, "Package "); d != docs {
+		docs = d
+	} else {
+		docs = strings.TrimPrefix(docs, "package ")
+	}
+	docs = strings.TrimPrefix(docs, pkgName)
+	docs = strings.TrimPrefix(docs, " ")
+	return docs
+}
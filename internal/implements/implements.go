@@ -0,0 +1,226 @@
+// Package implements type checks packages from source to find the types which
+// implement an interface, and the interfaces which a type implements.
+package implements
+
+import (
+	"flag"
+	"go/token"
+	"go/types"
+	"sort"
+
+	_dlog "aslevy.com/go-doc/internal/dlog"
+)
+
+var dlog = _dlog.Child("implements")
+
+var (
+	// Impls lists the types which implement an interface, after its docs.
+	Impls bool
+	// Implements lists the interfaces which a type implements, after its
+	// docs.
+	Implements bool
+)
+
+func AddFlags(fs *flag.FlagSet) {
+	fs.BoolVar(&Impls, "impls", false, "list the types in the local module and its dependencies which implement an interface")
+	fs.BoolVar(&Implements, "implements", false, "list the interfaces in scope or well known which a type implements")
+}
+
+// Impl is a named type which implements an interface.
+type Impl struct {
+	// Type is T, or *T if only the pointer implements the interface.
+	Type types.Type
+	Pos  token.Position
+}
+
+// FindImpls returns the named types of all loaded packages which implement
+// iface, ordered by import path and then by name. Interfaces and generic types
+// are not included, nor are the types of packages which are not importable, or
+// unexported types unless unexported is true.
+func FindImpls(l *Loader, iface *types.Interface, unexported bool) []Impl {
+	var impls []Impl
+	for _, pkg := range sortedPackages(l.Packages()) {
+		if !l.Importable(pkg.Path()) {
+			continue
+		}
+		scope := pkg.Scope()
+		for _, name := range scope.Names() {
+			obj, ok := scope.Lookup(name).(*types.TypeName)
+			if !ok || obj.IsAlias() || !obj.Exported() && !unexported {
+				continue
+			}
+			named, ok := obj.Type().(*types.Named)
+			if !ok || named.TypeParams().Len() > 0 || types.IsInterface(named) {
+				continue
+			}
+			var typ types.Type
+			switch {
+			case types.Implements(named, iface):
+				typ = named
+			case types.Implements(types.NewPointer(named), iface):
+				typ = types.NewPointer(named)
+			default:
+				continue
+			}
+			impls = append(impls, Impl{Type: typ, Pos: l.fset.Position(obj.Pos())})
+		}
+	}
+	return impls
+}
+
+// Interface is an interface which a type implements, or nearly implements.
+type Interface struct {
+	Obj *types.TypeName
+	// PointerOnly is true if only the pointer to the type implements the
+	// interface.
+	PointerOnly bool
+
+	// Missing is the first method the type is missing, if it does not
+	// implement the interface. If WrongType is true, the type has the
+	// method, but with Have as its signature.
+	Missing   *types.Func
+	WrongType bool
+	Have      *types.Func
+}
+
+// FindInterfaces returns the interfaces which named, or a pointer to it,
+// implements. The interfaces considered are those declared by the package of
+// named and by the packages it imports, as well as the well known interfaces
+// of the standard library. Interfaces which named nearly implements, because
+// it is missing just one of their methods or has one with the wrong signature,
+// are also returned with that method.
+//
+// Empty interfaces and constraints are never included.
+func FindInterfaces(l *Loader, named *types.Named) []Interface {
+	ptr := types.NewPointer(named)
+	methods := types.NewMethodSet(ptr)
+
+	var ifaces []Interface
+	seen := make(map[*types.TypeName]bool)
+	for _, obj := range candidateInterfaces(l, named.Obj().Pkg()) {
+		if seen[obj] || obj == named.Obj() {
+			continue
+		}
+		seen[obj] = true
+		iface := obj.Type().Underlying().(*types.Interface)
+		if iface.NumMethods() == 0 || !iface.IsMethodSet() {
+			continue
+		}
+		if types.Implements(ptr, iface) {
+			ifaces = append(ifaces, Interface{
+				Obj:         obj,
+				PointerOnly: !types.Implements(named, iface),
+			})
+			continue
+		}
+		if missing := numMissing(methods, iface); missing > 1 || missing == iface.NumMethods() {
+			continue
+		}
+		missing, wrongType := types.MissingMethod(ptr, iface, true)
+		if missing == nil {
+			continue
+		}
+		impl := Interface{Obj: obj, Missing: missing, WrongType: wrongType}
+		if wrongType {
+			if sel := methods.Lookup(missing.Pkg(), missing.Name()); sel != nil {
+				impl.Have, _ = sel.Obj().(*types.Func)
+			}
+		}
+		ifaces = append(ifaces, impl)
+	}
+	return ifaces
+}
+
+// wellKnown are the interfaces of the standard library which are always
+// considered by FindInterfaces.
+var wellKnown = []struct{ importPath, name string }{
+	{"", "error"},
+	{"fmt", "Stringer"},
+	{"fmt", "GoStringer"},
+	{"fmt", "Formatter"},
+	{"io", "Reader"},
+	{"io", "Writer"},
+	{"io", "Closer"},
+	{"io", "Seeker"},
+	{"io", "ReadWriter"},
+	{"io", "ReadCloser"},
+	{"io", "WriteCloser"},
+	{"io", "ReaderAt"},
+	{"io", "WriterAt"},
+	{"io", "ReaderFrom"},
+	{"io", "WriterTo"},
+	{"io", "ByteReader"},
+	{"io", "ByteWriter"},
+	{"io", "RuneReader"},
+	{"io", "StringWriter"},
+	{"context", "Context"},
+	{"encoding", "TextMarshaler"},
+	{"encoding", "TextUnmarshaler"},
+	{"encoding", "BinaryMarshaler"},
+	{"encoding", "BinaryUnmarshaler"},
+	{"encoding/json", "Marshaler"},
+	{"encoding/json", "Unmarshaler"},
+	{"database/sql", "Scanner"},
+	{"database/sql/driver", "Valuer"},
+	{"flag", "Value"},
+	{"hash", "Hash"},
+	{"net/http", "Handler"},
+	{"sort", "Interface"},
+}
+
+// candidateInterfaces returns the interfaces declared by pkg, then the
+// exported interfaces of the packages it imports, then the well known
+// interfaces.
+func candidateInterfaces(l *Loader, pkg *types.Package) []*types.TypeName {
+	var objs []*types.TypeName
+	addScope := func(scope *types.Scope, exportedOnly bool) {
+		for _, name := range scope.Names() {
+			obj, ok := scope.Lookup(name).(*types.TypeName)
+			if !ok || exportedOnly && !obj.Exported() {
+				continue
+			}
+			if named, ok := obj.Type().(*types.Named); ok && named.TypeParams().Len() > 0 {
+				continue
+			}
+			if types.IsInterface(obj.Type()) {
+				objs = append(objs, obj)
+			}
+		}
+	}
+	addScope(pkg.Scope(), false)
+	for _, imp := range sortedPackages(pkg.Imports()) {
+		addScope(imp.Scope(), true)
+	}
+	for _, known := range wellKnown {
+		scope := types.Universe
+		if known.importPath != "" {
+			pkg, err := l.Import(known.importPath)
+			if err != nil || pkg == nil {
+				dlog.Printf("failed to load %s: %v", known.importPath, err)
+				continue
+			}
+			scope = pkg.Scope()
+		}
+		if obj, ok := scope.Lookup(known.name).(*types.TypeName); ok {
+			objs = append(objs, obj)
+		}
+	}
+	return objs
+}
+
+// numMissing returns the number of methods of iface which are not in methods.
+func numMissing(methods *types.MethodSet, iface *types.Interface) (n int) {
+	for i := 0; i < iface.NumMethods(); i++ {
+		m := iface.Method(i)
+		if methods.Lookup(m.Pkg(), m.Name()) == nil {
+			n++
+		}
+	}
+	return n
+}
+
+func sortedPackages(pkgs []*types.Package) []*types.Package {
+	pkgs = append([]*types.Package(nil), pkgs...)
+	sort.Slice(pkgs, func(i, j int) bool { return pkgs[i].Path() < pkgs[j].Path() })
+	return pkgs
+}
@@ -0,0 +1,177 @@
+package implements
+
+import (
+	"context"
+	"go/build"
+	"go/types"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"aslevy.com/go-doc/internal/godoc"
+	"aslevy.com/go-doc/internal/testutil"
+)
+
+func testLoader(t *testing.T) (*Loader, godoc.PackageDir) {
+	root := t.TempDir()
+	testutil.WriteFiles(t, root, map[string]string{
+		"go.mod": "module example.com/m\n",
+		"api/api.go": `package api
+
+type Doer interface {
+	Do() error
+	Close() error
+}
+`,
+		"impl/impl.go": `package impl
+
+import "fmt"
+
+// Doer is in scope of the types of this package.
+type Doer interface {
+	Do() error
+	Close() error
+}
+
+type Value struct{}
+
+func (Value) Do() error    { return nil }
+func (Value) Close() error { return nil }
+
+type Pointer struct{}
+
+func (*Pointer) Do() error    { return nil }
+func (*Pointer) Close() error { return nil }
+func (*Pointer) String() string { return fmt.Sprint("pointer") }
+
+type Missing struct{}
+
+func (Missing) Do() error { return nil }
+
+type WrongType struct{}
+
+func (WrongType) Do()          {}
+func (WrongType) Close() error { return nil }
+
+type unexported struct{ Value }
+`,
+		"internal/hidden/hidden.go": `package hidden
+
+type Hidden struct{}
+
+func (Hidden) Do() error    { return nil }
+func (Hidden) Close() error { return nil }
+`,
+	})
+	local := godoc.NewPackageDir("example.com/m", root)
+	std := godoc.NewPackageDir("", filepath.Join(build.Default.GOROOT, "src"))
+	return NewLoader([]godoc.PackageDir{std, local}), local
+}
+
+func TestFindImpls(t *testing.T) {
+	require := require.New(t)
+	l, local := testLoader(t)
+
+	api, err := l.Import("example.com/m/api")
+	require.NoError(err)
+	iface := api.Scope().Lookup("Doer").Type().Underlying().(*types.Interface)
+
+	require.NoError(l.LoadAll(context.Background(), []godoc.PackageDir{local}))
+	var names []string
+	for _, impl := range FindImpls(l, iface, false) {
+		names = append(names, types.TypeString(impl.Type, nil))
+	}
+	require.Equal([]string{
+		"*example.com/m/impl.Pointer",
+		"example.com/m/impl.Value",
+		"example.com/m/internal/hidden.Hidden",
+	}, names)
+
+	// The internal packages of other modules are not importable.
+	l.localRoots = []godoc.PackageDir{godoc.NewPackageDir("example.com/other", "")}
+	require.Len(FindImpls(l, iface, false), 2)
+}
+
+func TestFindImpls_dependencies(t *testing.T) {
+	require := require.New(t)
+	l, local := testLoader(t)
+
+	// The local module does not import the packages of its dependency.
+	depRoot := t.TempDir()
+	testutil.WriteFiles(t, depRoot, map[string]string{
+		"go.mod": "module example.com/dep\n",
+		"dep.go": `package dep
+
+type Dep struct{}
+
+func (Dep) Do() error    { return nil }
+func (Dep) Close() error { return nil }
+`,
+		"internal/hidden/hidden.go": `package hidden
+
+type Hidden struct{}
+
+func (Hidden) Do() error    { return nil }
+func (Hidden) Close() error { return nil }
+`,
+	})
+	dep := godoc.NewPackageDir("example.com/dep", depRoot)
+	l.codeRoots = append(l.codeRoots, dep)
+
+	api, err := l.Import("example.com/m/api")
+	require.NoError(err)
+	iface := api.Scope().Lookup("Doer").Type().Underlying().(*types.Interface)
+
+	ctx := context.Background()
+	require.NoError(l.LoadAll(ctx, []godoc.PackageDir{local}))
+	require.NoError(l.LoadDependencies(ctx, []godoc.PackageDir{dep}))
+	var names []string
+	for _, impl := range FindImpls(l, iface, false) {
+		names = append(names, types.TypeString(impl.Type, nil))
+	}
+	// The internal packages of dependencies are not importable.
+	require.Equal([]string{
+		"example.com/dep.Dep",
+		"*example.com/m/impl.Pointer",
+		"example.com/m/impl.Value",
+		"example.com/m/internal/hidden.Hidden",
+	}, names)
+}
+
+func TestFindInterfaces(t *testing.T) {
+	require := require.New(t)
+	l, _ := testLoader(t)
+
+	impl, err := l.Import("example.com/m/impl")
+	require.NoError(err)
+	lookup := func(name string) []string {
+		named := impl.Scope().Lookup(name).Type().(*types.Named)
+		var ifaces []string
+		for _, iface := range FindInterfaces(l, named) {
+			s := iface.Obj.Pkg().Path() + "." + iface.Obj.Name()
+			switch {
+			case iface.WrongType:
+				s += " wrong type " + iface.Missing.Name()
+			case iface.Missing != nil:
+				s += " missing " + iface.Missing.Name()
+			case iface.PointerOnly:
+				s += " pointer"
+			}
+			ifaces = append(ifaces, s)
+		}
+		return ifaces
+	}
+
+	// The interfaces of example.com/m/api are neither in scope nor well
+	// known.
+	closers := []string{"io.ReadCloser missing Read", "io.WriteCloser missing Write"}
+	require.Equal(append([]string{
+		"example.com/m/impl.Doer pointer",
+		"fmt.Stringer pointer",
+		"io.Closer pointer",
+	}, append(closers, "flag.Value missing Set")...), lookup("Pointer"))
+	require.Equal(append([]string{"example.com/m/impl.Doer", "io.Closer"}, closers...), lookup("Value"))
+	require.Equal([]string{"example.com/m/impl.Doer missing Close"}, lookup("Missing"))
+	require.Equal(append([]string{"example.com/m/impl.Doer wrong type Do", "io.Closer"}, closers...), lookup("WrongType"))
+}
@@ -0,0 +1,239 @@
+package implements
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"go/ast"
+	"go/build"
+	"go/parser"
+	"go/token"
+	"go/types"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"aslevy.com/go-doc/internal/godoc"
+	"aslevy.com/go-doc/internal/walk"
+)
+
+// Loader type checks packages from source, along with all of the packages
+// they import. Function bodies are ignored, since only declarations matter for
+// method sets, and type errors are ignored, so that a package which does not
+// compile is still mostly usable.
+//
+// Loader implements [types.ImporterFrom].
+type Loader struct {
+	fset      *token.FileSet
+	codeRoots []godoc.PackageDir
+	// localRoots are the roots given to LoadAll.
+	localRoots []godoc.PackageDir
+
+	// Packages are keyed by directory, since packages vendored by GOROOT
+	// share their import paths with those of modules.
+	pkgs    map[string]*types.Package
+	loading map[string]bool
+	order   []string // directories, in the order they were loaded
+}
+
+// NewLoader returns a Loader which resolves import paths to the directories
+// of codeRoots, or of any vendor directory which applies to the importing
+// package.
+func NewLoader(codeRoots []godoc.PackageDir) *Loader {
+	return &Loader{
+		fset:      token.NewFileSet(),
+		codeRoots: codeRoots,
+		pkgs:      make(map[string]*types.Package),
+		loading:   make(map[string]bool),
+	}
+}
+
+// FileSet returns the file set for the positions of all loaded objects.
+func (l *Loader) FileSet() *token.FileSet { return l.fset }
+
+// Packages returns every package loaded so far, in the order they were
+// loaded, which is after all of their imports.
+func (l *Loader) Packages() []*types.Package {
+	pkgs := make([]*types.Package, len(l.order))
+	for i, dir := range l.order {
+		pkgs[i] = l.pkgs[dir]
+	}
+	return pkgs
+}
+
+func (l *Loader) Import(importPath string) (*types.Package, error) {
+	return l.ImportFrom(importPath, "", 0)
+}
+
+// ImportFrom loads the package with importPath, as imported by a package in
+// fromDir.
+func (l *Loader) ImportFrom(importPath, fromDir string, _ types.ImportMode) (*types.Package, error) {
+	if importPath == "unsafe" {
+		return types.Unsafe, nil
+	}
+	if dir := vendorDir(importPath, fromDir); dir != "" {
+		return l.LoadDir(importPath, dir)
+	}
+	dir := l.findDir(importPath)
+	if dir == "" {
+		return nil, fmt.Errorf("cannot find package %q", importPath)
+	}
+	return l.LoadDir(importPath, dir)
+}
+
+// LoadDir loads the package with importPath in dir.
+func (l *Loader) LoadDir(importPath, dir string) (*types.Package, error) {
+	dir = filepath.Clean(dir)
+	if pkg, ok := l.pkgs[dir]; ok {
+		return pkg, nil
+	}
+	if l.loading[dir] {
+		return nil, fmt.Errorf("import cycle through %q", importPath)
+	}
+	l.loading[dir] = true
+	defer delete(l.loading, dir)
+
+	buildPkg, err := build.ImportDir(dir, 0)
+	if err != nil {
+		return nil, err
+	}
+	var files []*ast.File
+	for _, name := range append(buildPkg.GoFiles, buildPkg.CgoFiles...) {
+		file, err := parser.ParseFile(l.fset, filepath.Join(dir, name), nil, parser.SkipObjectResolution)
+		if err != nil {
+			dlog.Printf("failed to parse %s: %v", name, err)
+		}
+		if file != nil {
+			files = append(files, file)
+		}
+	}
+
+	conf := types.Config{
+		Importer:         l,
+		IgnoreFuncBodies: true,
+		FakeImportC:      true,
+		// Ignore type errors, which are usually caused by packages we
+		// failed to find or parse, and keep checking.
+		Error: func(error) {},
+	}
+	pkg, _ := conf.Check(importPath, l.fset, files, nil)
+	l.pkgs[dir] = pkg
+	l.order = append(l.order, dir)
+	return pkg, nil
+}
+
+// LoadAll loads every package within the module roots, which are local, so
+// their internal packages are importable. See walk.Module.
+func (l *Loader) LoadAll(ctx context.Context, roots []godoc.PackageDir) error {
+	l.localRoots = append(l.localRoots, roots...)
+	return l.LoadDependencies(ctx, roots)
+}
+
+// LoadDependencies loads every package within the roots of the modules which
+// the local modules require, like LoadAll, except that their internal packages
+// are not importable.
+func (l *Loader) LoadDependencies(ctx context.Context, roots []godoc.PackageDir) error {
+	for _, root := range roots {
+		err := walk.Module(ctx, root, func(pkg godoc.PackageDir) error {
+			var noGo *build.NoGoError
+			if _, err := l.LoadDir(pkg.ImportPath, pkg.Dir); err != nil && !errors.As(err, &noGo) {
+				dlog.Printf("failed to load %s: %v", pkg.ImportPath, err)
+			}
+			return nil
+		})
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Importable reports whether a package within the roots given to LoadAll may
+// import the package with importPath, which is not the case if it is internal
+// to another module.
+func (l *Loader) Importable(importPath string) bool {
+	parent, ok := internalParent(importPath)
+	if !ok {
+		return true
+	}
+	for _, root := range l.localRoots {
+		if root.ImportPath == parent || strings.HasPrefix(root.ImportPath, parent+"/") {
+			return true
+		}
+	}
+	return false
+}
+
+// internalParent returns the import path of the directory containing the last
+// internal element of importPath, if any. The parent of the internal packages
+// of the standard library is the empty string, which no module is within.
+func internalParent(importPath string) (string, bool) {
+	switch {
+	case strings.HasSuffix(importPath, "/internal"):
+		return strings.TrimSuffix(importPath, "/internal"), true
+	case importPath == "internal", strings.HasPrefix(importPath, "internal/"):
+		return "", true
+	}
+	if i := strings.LastIndex(importPath, "/internal/"); i >= 0 {
+		return importPath[:i], true
+	}
+	return "", false
+}
+
+// findDir returns the directory of the package with importPath in the code
+// root with the longest matching import path, or the empty string if there is
+// none.
+func (l *Loader) findDir(importPath string) (dir string) {
+	longest := -1
+	for _, root := range l.codeRoots {
+		rel, ok := cutImportPath(importPath, root.ImportPath)
+		if !ok || len(root.ImportPath) <= longest {
+			continue
+		}
+		candidate := filepath.Join(root.Dir, filepath.FromSlash(rel))
+		if isDir(candidate) {
+			dir, longest = candidate, len(root.ImportPath)
+		}
+	}
+	return dir
+}
+
+// cutImportPath returns importPath relative to the import path of a code
+// root, and whether importPath is within the code root.
+func cutImportPath(importPath, rootImportPath string) (string, bool) {
+	switch {
+	case rootImportPath == "":
+		return importPath, true
+	case importPath == rootImportPath:
+		return "", true
+	}
+	return strings.CutPrefix(importPath, rootImportPath+"/")
+}
+
+// vendorDir returns the directory of the package with importPath within the
+// vendor directory of fromDir or of any of its parents, up to the root of the
+// module, or the empty string if there is none.
+func vendorDir(importPath, fromDir string) string {
+	if fromDir == "" {
+		return ""
+	}
+	for dir := fromDir; ; {
+		vendored := filepath.Join(dir, "vendor", filepath.FromSlash(importPath))
+		if isDir(vendored) {
+			return vendored
+		}
+		if _, err := os.Stat(filepath.Join(dir, "go.mod")); err == nil {
+			return ""
+		}
+		parent := filepath.Dir(dir)
+		if parent == dir {
+			return ""
+		}
+		dir = parent
+	}
+}
+
+func isDir(dir string) bool {
+	info, err := os.Stat(dir)
+	return err == nil && info.IsDir()
+}
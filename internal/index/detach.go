@@ -0,0 +1,30 @@
+package index
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"sync"
+)
+
+// DetachedSync returns a function for WithDetachedSync which starts this
+// executable with args, which should include -index-sync, in a new process
+// which outlives this one. The process is started at most once, however many
+// indexes are loaded with the function.
+func DetachedSync(args ...string) func() error {
+	return sync.OnceValue(func() error {
+		exe, err := os.Executable()
+		if err != nil {
+			return fmt.Errorf("failed to locate executable: %w", err)
+		}
+		cmd := exec.Command(exe, args...)
+		cmd.SysProcAttr = detachedProcAttr()
+		if err := cmd.Start(); err != nil {
+			return fmt.Errorf("failed to start detached sync: %w", err)
+		}
+		dlogSync.Printf("started detached sync in process %d", cmd.Process.Pid)
+		// Reap the process if this one lives long enough, like a daemon.
+		go cmd.Wait()
+		return nil
+	})
+}
@@ -0,0 +1,7 @@
+//go:build !unix
+
+package index
+
+import "syscall"
+
+func detachedProcAttr() *syscall.SysProcAttr { return nil }
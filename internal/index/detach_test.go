@@ -0,0 +1,59 @@
+package index
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"aslevy.com/go-doc/internal/godoc"
+)
+
+func TestLoad_detachedSync(t *testing.T) {
+	require := require.New(t)
+	ctx := context.Background()
+	root := t.TempDir()
+	writePkg := func(name string) {
+		dir := filepath.Join(root, name)
+		require.NoError(os.MkdirAll(dir, 0755))
+		require.NoError(os.WriteFile(filepath.Join(dir, name+".go"), []byte("package "+name+"\n"), 0644))
+	}
+	writePkg("first")
+	codeRoots := []godoc.PackageDir{godoc.NewPackageDir("example.com/app", root)}
+	dbPath := dbFilePath(t)
+
+	var started int
+	detachSync := func(err error) Option {
+		return WithDetachedSync(func() error {
+			started++
+			return err
+		})
+	}
+	search := func(opts ...Option) []string {
+		pkgIdx, err := Load(ctx, dbPath, codeRoots, append(opts, loadOpts())...)
+		require.NoError(err)
+		defer pkgIdx.Close()
+		pkgs, err := pkgIdx.Search(ctx, "app", WithMatchPartials())
+		require.NoError(err)
+		return importPaths(pkgs)
+	}
+
+	// The first sync cannot be left to another process.
+	require.Equal([]string{"example.com/app/first"}, search(detachSync(nil)))
+	require.Zero(started)
+
+	writePkg("second")
+	require.Equal([]string{"example.com/app/first"}, search(detachSync(nil)))
+	require.Equal(1, started)
+
+	// The index is synced by this process if the detached sync fails to
+	// start, or is forced.
+	require.Len(search(detachSync(errors.New("failed"))), 2)
+	require.Equal(2, started)
+	writePkg("third")
+	require.Len(search(detachSync(nil), WithForceSync()), 3)
+	require.Equal(2, started)
+}
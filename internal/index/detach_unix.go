@@ -0,0 +1,9 @@
+//go:build unix
+
+package index
+
+import "syscall"
+
+// detachedProcAttr starts the detached sync in a new session, so that it is
+// not sent the signals of the terminal of this process.
+func detachedProcAttr() *syscall.SysProcAttr { return &syscall.SysProcAttr{Setsid: true} }
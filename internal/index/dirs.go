@@ -15,12 +15,27 @@ type Dirs struct {
 	g             *errgroup.Group
 	cancel        context.CancelFunc
 
-	next    chan godoc.PackageDir
+	next    chan summarizedPackageDir
 	results []godoc.PackageDir
 	offset  int
+	// summaries holds the summaries of the results, keyed by their
+	// directory.
+	summaries map[string]godoc.PackageSummary
 }
 
-var _ godoc.Dirs = (*Dirs)(nil)
+type summarizedPackageDir struct {
+	godoc.PackageDir
+	summary godoc.PackageSummary
+}
+
+var (
+	_ godoc.Dirs               = (*Dirs)(nil)
+	_ godoc.NotRequiredDirs    = (*Dirs)(nil)
+	_ godoc.ModuleDirs         = (*Dirs)(nil)
+	_ godoc.ModuleVersionDirs  = (*Dirs)(nil)
+	_ godoc.PackageNameDirs    = (*Dirs)(nil)
+	_ godoc.PackageSummaryDirs = (*Dirs)(nil)
+)
 
 func NewDirs(pkgIdx *Index) godoc.Dirs {
 	return &Dirs{idx: pkgIdx}
@@ -34,15 +49,29 @@ func (d *Dirs) Next() (pkg godoc.PackageDir, ok bool) {
 		return pkg, true
 	}
 
-	pkg, ok = <-d.next
+	next, ok := <-d.next
 	if ok {
-		d.results = append(d.results, pkg)
+		d.results = append(d.results, next.PackageDir)
+		d.summaries[next.Dir] = next.summary
 		d.offset++
 	}
-	return pkg, ok
+	return next.PackageDir, ok
+}
+func (d *Dirs) FilterExact(path string) error           { return d.filter(path) }
+func (d *Dirs) FilterPartial(path string) error         { return d.filter(path, WithMatchPartials()) }
+func (d *Dirs) NotRequired(dir string) (string, bool)   { return d.idx.NotRequired(dir) }
+func (d *Dirs) Module(dir string) (godoc.Module, bool)  { return d.idx.Module(dir) }
+func (d *Dirs) ModuleVersion(dir string) (string, bool) { return d.idx.ModuleVersion(dir) }
+func (d *Dirs) PackageName(dir string) string           { return d.summaries[dir].Name }
+
+// PackageSummary returns the summary of the package in dir, if it was returned
+// by Next. The summary of a package which failed to load when it was synced,
+// e.g. because its files declare different package names, is empty, so it is
+// not known.
+func (d *Dirs) PackageSummary(dir string) (godoc.PackageSummary, bool) {
+	summary, ok := d.summaries[dir]
+	return summary, ok && summary.Name != ""
 }
-func (d *Dirs) FilterExact(path string) error   { return d.filter(path) }
-func (d *Dirs) FilterPartial(path string) error { return d.filter(path, WithMatchPartials()) }
 func (d *Dirs) filter(path string, opts ...SearchOption) error {
 	o := newSearchOptions(opts...)
 	if d.searchPath == path && d.searchPartial == o.matchPartials {
@@ -61,23 +90,27 @@ func (d *Dirs) filter(path string, opts ...SearchOption) error {
 
 	rows, err := d.idx.searchRows(ctx, path, opts...)
 	if err != nil {
+		cancel()
 		return err
 	}
 
 	d.searchPath = path
 	d.searchPartial = o.matchPartials
-	d.next = make(chan godoc.PackageDir)
+	d.next = make(chan summarizedPackageDir)
+	if d.summaries == nil {
+		d.summaries = make(map[string]godoc.PackageSummary)
+	}
 
 	d.cancel = cancel
 	d.g, ctx = errgroup.WithContext(ctx)
 	d.g.Go(func() error {
 		defer cancel()
 		defer close(d.next)
-		return scanPackageDirs(rows, func(pkg godoc.PackageDir) error {
+		return scanPackageDirs(rows, func(pkg godoc.PackageDir, summary godoc.PackageSummary) error {
 			select {
 			case <-ctx.Done():
 				return ctx.Err()
-			case d.next <- pkg:
+			case d.next <- summarizedPackageDir{pkg, summary}:
 			}
 			return nil
 		})
@@ -0,0 +1,162 @@
+package index
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"strings"
+
+	"aslevy.com/go-doc/internal/godoc"
+)
+
+// DocMatch is a package or symbol whose doc matched a SearchDocs query.
+type DocMatch struct {
+	godoc.PackageDir
+	// Name is the package name for package docs, otherwise <sym> or
+	// <type>.<method|field>.
+	Name string
+	// Kind is the kind of the symbol, or empty for package docs.
+	Kind godoc.SymbolKind
+	// Summary is the one-line summary of the symbol, or empty for package
+	// docs.
+	Summary string
+	// Snippet is an excerpt of the doc with the matching terms enclosed in
+	// the WithHighlight delimiters.
+	Snippet string
+}
+
+// IsPackage reports whether the match is of the package doc rather than a
+// symbol.
+func (m DocMatch) IsPackage() bool { return m.Kind == "" }
+
+// SearchDocs returns the packages and symbols whose names or doc comments
+// match all of the words in query, ranked by relevance. The docs of modules
+// which are not required rank last.
+//
+// A word ending in * matches any word with that prefix. Words are matched
+// after stemming, so "retry" also matches "retries" and "retrying".
+//
+// Docs are only indexed if the Index was loaded WithSymbolLoader.
+func (idx *Index) SearchDocs(ctx context.Context, query string, opts ...SearchOption) ([]DocMatch, error) {
+	if err := idx.waitSync(); err != nil {
+		return nil, err
+	}
+
+	match := ftsQuery(query)
+	if match == "" {
+		return nil, nil
+	}
+	dlogSearch.Printf("docs match: %s", match)
+
+	o := newSearchOptions(opts...)
+	limit := o.limit
+	if limit <= 0 {
+		limit = -1 // no limit
+	}
+
+	// The name column is weighted so that symbols named for the query rank
+	// above those which merely mention it.
+	const selectDocs = `
+SELECT
+  packageImportPath,
+  packageDir,
+  docs.name,
+  ifnull(symbol.kind, ''),
+  ifnull(symbol.summary, ''),
+  snippet(docs, 1, ?, ?, '...', 16),
+  bm25(docs, 10.0, 1.0) AS rank,
+  class,
+  class = %[3]d AS notRequired,
+  moduleImportPath,
+  relativeNumParts,
+  relativePath
+FROM
+  %[1]s.docs AS docs
+  INNER JOIN %[2]s AS package
+    ON docs.packageId = package.rowid
+  LEFT JOIN %[1]s.symbol AS symbol
+    ON docs.rowid = symbol.rowid
+WHERE
+  docs MATCH ?
+`
+	selectQuery := fmt.Sprintf(selectDocs, "main", "main.modulePackage", classNotRequired)
+	params := []any{o.highlightOpen, o.highlightClose, match}
+	if idx.shared != nil {
+		// FTS5 tables cannot be queried through a view, so the docs of
+		// the shared index are searched separately.
+		selectQuery += "UNION ALL" + fmt.Sprintf(selectDocs, "shared", "sharedModulePackage", classNotRequired)
+		params = append(params, o.highlightOpen, o.highlightClose, match)
+	}
+	selectQuery += `
+ORDER BY
+  notRequired      ASC,
+  rank             ASC,
+  class            ASC,
+  moduleImportPath ASC,
+  relativeNumParts ASC,
+  relativePath     ASC
+LIMIT ?;
+`
+	rows, err := idx.db.QueryContext(ctx, selectQuery, append(params, limit)...)
+	if err != nil {
+		return nil, err
+	}
+	var matches []DocMatch
+	return matches, scanDocMatches(rows, func(match DocMatch) error {
+		matches = append(matches, match)
+		return nil
+	})
+}
+func scanDocMatches(rows *sql.Rows, handler func(DocMatch) error) error {
+	defer rows.Close()
+	for rows.Next() {
+		match, err := scanDocMatch(rows)
+		if err != nil {
+			return err
+		}
+		if err := handler(match); err != nil {
+			return err
+		}
+	}
+	return rows.Err()
+}
+func scanDocMatch(row sqlRow) (DocMatch, error) {
+	var match DocMatch
+	return match, row.Scan(
+		&match.ImportPath,
+		&match.Dir,
+		&match.Name,
+		&match.Kind,
+		&match.Summary,
+		&match.Snippet,
+		new(float64), // rank
+		new(int),     // class
+		new(bool),    // notRequired
+		new(string),  // moduleImportPath
+		new(int),     // relativeNumParts
+		new(string),  // relativePath
+	)
+}
+
+// ftsQuery converts the words in query into an FTS5 query which matches all
+// of the words.
+//
+// Each word is quoted so that punctuation in the query is never interpreted as
+// FTS5 query syntax, which would otherwise be an error for queries like
+// "Client.Do".
+func ftsQuery(query string) string {
+	var words []string
+	for _, word := range strings.Fields(query) {
+		prefix := strings.HasSuffix(word, "*")
+		word = strings.TrimRight(word, "*")
+		if word == "" {
+			continue
+		}
+		word = `"` + strings.ReplaceAll(word, `"`, `""`) + `"`
+		if prefix {
+			word += "*"
+		}
+		words = append(words, word)
+	}
+	return strings.Join(words, " ")
+}
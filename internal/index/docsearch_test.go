@@ -0,0 +1,51 @@
+package index
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestSearchDocs(t *testing.T) {
+	ctx := context.Background()
+	pkgIdx, err := Load(ctx, dbMem, testdataCodeRoots(), loadOpts(), WithSymbolLoader(testSymbols))
+	require.NoError(t, err)
+	t.Cleanup(func() { require.NoError(t, pkgIdx.Close()) })
+
+	tests := []struct {
+		query   string
+		results []string
+	}{{
+		query:   "constructor",
+		results: []string{"aslevy.com/go-doc/testdata:ExportedTypeConstructor:Comment about [constructor] for exported type.\n"},
+	}, {
+		// Package docs are indexed by package name.
+		query:   "comment B",
+		results: []string{"aslevy.com/go-doc/testdata/merge:merge:Package [comment] A.\n\nPackage [comment] [B].\n"},
+	}, {
+		// Punctuation is not interpreted as query syntax.
+		query:   "pre-formatted",
+		results: []string{"aslevy.com/go-doc/testdata:ExportedFormattedDoc:Comment about exported function with formatting.\n\nExample\n\n\tfmt.Println(FormattedDoc())\n\nText after [pre-formatted] block.\n"},
+	}, {
+		query:   "export* interface",
+		results: []string{"aslevy.com/go-doc/testdata:ExportedInterface:Comment about [exported] [interface].\n"},
+	}, {
+		query: "Client.Do",
+	}, {
+		query: `"`,
+	}, {
+		query: "*",
+	}}
+	for _, test := range tests {
+		t.Run(test.query, func(t *testing.T) {
+			matches, err := pkgIdx.SearchDocs(ctx, test.query, WithHighlight("[", "]"))
+			require.NoError(t, err)
+			var results []string
+			for _, match := range matches {
+				results = append(results, match.ImportPath+":"+match.Name+":"+match.Snippet)
+			}
+			require.Equal(t, test.results, results)
+		})
+	}
+}
@@ -4,25 +4,60 @@ import (
 	"flag"
 	"fmt"
 	"os"
-	"time"
+	"strconv"
 
 	_dlog "aslevy.com/go-doc/internal/dlog"
 	"aslevy.com/go-doc/internal/flagvar"
 )
 
 const (
-	SyncEnvVar            = "GODOC_INDEX_MODE"
-	ResyncEnvVar          = "GODOC_INDEX_RESYNC"
-	DefaultResyncInterval = 20 * time.Minute
-	NoProgressBar         = "GODOC_NO_PROGRESS_BAR"
+	SyncEnvVar    = "GODOC_INDEX_MODE"
+	NoProgressBar = "GODOC_NO_PROGRESS_BAR"
+
+	// DirEnvVar is the directory in which to store the index of each
+	// module, instead of the .go-doc directory in the module root.
+	DirEnvVar = "GODOC_INDEX_DIR"
+
+	// ModCacheEnvVar enables ModCache if set to a true value.
+	ModCacheEnvVar = "GODOC_INDEX_MODCACHE"
+
+	// InMemory is the path to an index which is not persisted.
+	InMemory = ":memory:"
 )
 
 var (
-	dlog           = _dlog.Child("index")
-	Sync           = ModeAutoSync
-	ResyncInterval = DefaultResyncInterval
+	dlog = _dlog.Child("index")
+	Sync = ModeAutoSync
+
+	// ModCache is true if the modules in GOMODCACHE which are not
+	// required should also be indexed. See WithModCache.
+	ModCache bool
+
+	// Progress is how the progress of a sync is shown on stderr. See
+	// ParseProgress.
+	Progress = ProgressBar
+
+	// Which is the symbol to look up in all indexed packages, if set.
+	Which string
+	// SearchQuery is the full text search query for all indexed docs, if
+	// set.
+	SearchQuery string
+
+	// ShowStats, ShowPath, Rebuild, Vacuum and SyncOnly are the index
+	// maintenance commands. See IsCommand.
+	ShowStats bool
+	ShowPath  bool
+	Rebuild   bool
+	Vacuum    bool
+	// SyncOnly syncs the index if a resync is due, and is used by the
+	// detached sync. See DetachedSync.
+	SyncOnly bool
 )
 
+// IsCommand reports whether any index maintenance command was given, in which
+// case go doc should run the commands instead of printing docs.
+func IsCommand() bool { return ShowStats || ShowPath || Rebuild || Vacuum || SyncOnly }
+
 func AddFlags(fs *flag.FlagSet) {
 	debugDesc := "enable debug logging for index"
 	fs.Var(dlog.EnableFlag(), "debug-index", debugDesc)
@@ -31,12 +66,19 @@ func AddFlags(fs *flag.FlagSet) {
 
 	Sync, _ = ParseMode(os.Getenv(SyncEnvVar))
 	fs.Var(flagvar.Parse(&Sync, ParseMode), "index-mode", fmt.Sprintf("cached index modes: %s", modes()))
-	fs.DurationVar(&ResyncInterval, "index-resync", parseResyncInterval(os.Getenv(ResyncEnvVar)), "resync index if older than this duration")
-}
-func parseResyncInterval(s string) time.Duration {
-	d, err := time.ParseDuration(s)
-	if err != nil {
-		return DefaultResyncInterval
+	modCache, _ := strconv.ParseBool(os.Getenv(ModCacheEnvVar))
+	if noProgressBar, _ := strconv.ParseBool(os.Getenv(NoProgressBar)); noProgressBar {
+		Progress = ProgressOff
 	}
-	return d
+	fs.Var(flagvar.Parse(&Progress, ParseProgress), "index-progress", fmt.Sprintf("sync progress output on stderr: %s", progressModes()))
+	fs.BoolVar(&ModCache, "index-modcache", modCache, "also index the latest version of every module in GOMODCACHE which is not required")
+
+	fs.StringVar(&Which, "which", "", "list all indexed packages which export `symbol`, i.e. Marshal or Client.Do")
+	fs.StringVar(&SearchQuery, "search", "", "full text search the docs of all indexed packages and symbols for `words`")
+
+	fs.BoolVar(&ShowStats, "index-stats", false, "print the index metadata and modules, and whether a resync is due")
+	fs.BoolVar(&ShowPath, "index-path", false, "print the path to the index database")
+	fs.BoolVar(&Rebuild, "index-rebuild", false, "delete and rebuild the index from scratch")
+	fs.BoolVar(&Vacuum, "index-vacuum", false, "reclaim unused space in the index database")
+	fs.BoolVar(&SyncOnly, "index-sync", false, "sync the index if a resync is due, and wait for it to finish")
 }
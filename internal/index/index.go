@@ -1,11 +1,11 @@
 package index
 
 import (
-	"bufio"
-	"bytes"
 	"context"
 	"database/sql"
 	"fmt"
+	"strings"
+	"sync"
 
 	"golang.org/x/sync/errgroup"
 	_ "modernc.org/sqlite"
@@ -16,10 +16,40 @@ import (
 type Index struct {
 	options
 
-	db *sql.DB
-	tx *sqlTx
+	dbPath    string
+	lock      *syncLock
+	codeRoots []godoc.PackageDir
+	db        *sql.DB
+	tx        *sqlTx
+
+	// notRequired holds the directories of the code roots found in the
+	// module cache. See WithModCache.
+	notRequired map[string]bool
+	// notRequiredDirs returns the import paths of the modules of the
+	// index which are not required, keyed by their directory. See
+	// NotRequired.
+	notRequiredDirs func() map[string]string
+	// modules returns the modules of the index, keyed by their
+	// directory. See Module.
+	modules func() map[string]godoc.Module
+
+	// shared is the shared index attached to db, if any.
+	shared *Index
 
 	metadata
+	// moduleFiles are the hashes of the module files, while a sync is in
+	// progress.
+	moduleFiles moduleFiles
+	// syncDue reports whether the index needed to sync when it was loaded.
+	// It is only checked once, since the module cache is only walked for a
+	// sync. See notRequiredCodeRoots.
+	syncDue func() (bool, error)
+	// loadedModuleFiles are the hashes of the module files when the index
+	// was loaded. See ModuleFilesChanged.
+	loadedModuleFiles moduleFiles
+
+	// progress reports the progress of a sync, while one is in progress.
+	progress *syncProgress
 
 	cancel context.CancelFunc
 	g      *errgroup.Group
@@ -31,32 +61,106 @@ func Load(ctx context.Context, dbPath string, codeRoots []godoc.PackageDir, opts
 		return nil, nil
 	}
 
-	dlog.Printf("loading %q", dbPath)
-	dlog.Printf("options: %+v", o)
-	db, err := sql.Open("sqlite", dbPath)
-	if err != nil {
-		return nil, fmt.Errorf("failed to open index database: %w", err)
+	if _, isFile := dataSourceFile(dbPath); !isFile {
+		// The shared index is attached by reopening the database,
+		// which would discard an in-memory database.
+		o.sharedPath = ""
 	}
 
+	dlog.Printf("loading %q", dbPath)
+	dlog.Printf("options: %+v", o)
 	idx := Index{
-		options: o,
-		db:      db,
+		options:   o,
+		dbPath:    dbPath,
+		lock:      newSyncLock(dbPath),
+		codeRoots: codeRoots,
 	}
+	idx.notRequiredDirs = sync.OnceValue(idx.selectNotRequiredDirs)
+	idx.modules = sync.OnceValue(idx.selectModules)
+	idx.syncDue = sync.OnceValues(func() (bool, error) { return idx.needsSync(ctx, codeRoots) })
 
+	if err := idx.open(); err != nil {
+		return nil, fmt.Errorf("failed to open index database: %w", err)
+	}
 	if err := idx.initDB(ctx); err != nil {
 		return nil, err
 	}
 
+	// The shared index is given the code roots of the local index, so it
+	// does not look in the module cache itself.
+	if o.modCache != "" && !o.isShared {
+		notRequired := idx.notRequiredCodeRoots(ctx, codeRoots)
+		idx.notRequired = make(map[string]bool, len(notRequired))
+		for _, root := range notRequired {
+			idx.notRequired[root.Dir] = true
+		}
+		idx.codeRoots = append(codeRoots[:len(codeRoots):len(codeRoots)], notRequired...)
+		codeRoots = idx.codeRoots
+	}
+	idx.loadedModuleFiles = hashModuleFiles(codeRoots, o.goWork)
+	if o.sharedPath != "" {
+		var err error
+		idx.shared, err = Load(ctx, o.sharedPath, sharedCodeRoots(codeRoots), WithOptions(opts...), withIsShared())
+		if err != nil {
+			// The local index can still hold all packages.
+			dlog.Printf("failed to load shared index: %v", err)
+		}
+	}
+	if idx.shared != nil {
+		if err := idx.attachShared(); err != nil {
+			idx.closeShared()
+			return nil, fmt.Errorf("failed to attach shared index: %w", err)
+		}
+	}
+
 	ctx, idx.cancel = context.WithCancel(ctx)
 	idx.g, ctx = errgroup.WithContext(ctx)
 	idx.g.Go(func() error {
 		defer idx.cancel()
 		return idx.syncCodeRoots(ctx, codeRoots)
 	})
+	if idx.shared != nil {
+		idx.g.Go(idx.shared.waitSync)
+	}
 
 	return &idx, nil
 }
 
+// dataSourceName returns dbPath with the query parameters which set the
+// pragmas required on every connection to the database.
+//
+// Pragmas like foreign_keys are per connection, and database/sql may open
+// multiple connections, so they must be set by the driver when connecting.
+//
+// Many go-doc processes may use the same index at once, so connections wait
+// for locks held by other processes rather than failing with "database is
+// locked", and the WAL journal mode lets them keep reading while another
+// process syncs. Write transactions take their lock immediately, since
+// a deferred transaction which later fails to upgrade its lock is not retried.
+// An immediate transaction locks every attached database which is writable,
+// so the shared index is attached read-only. See attachShared.
+func dataSourceName(dbPath string) string {
+	sep := "?"
+	if strings.Contains(dbPath, "?") {
+		sep = "&"
+	}
+	params := []string{
+		"_pragma=busy_timeout(10000)",
+		"_pragma=foreign_keys(1)",
+		"_txlock=immediate",
+	}
+	if _, isFile := dataSourceFile(dbPath); isFile {
+		params = append(params, "_pragma=journal_mode(WAL)")
+	}
+	return dbPath + sep + strings.Join(params, "&")
+}
+
+func (idx *Index) open() error {
+	var err error
+	idx.db, err = sql.Open("sqlite", dataSourceName(idx.dbPath))
+	return err
+}
+
 func (idx *Index) waitSync() error { return idx.g.Wait() }
 
 func (idx *Index) Close() error {
@@ -64,94 +168,14 @@ func (idx *Index) Close() error {
 	if err := idx.waitSync(); err != nil {
 		dlog.Printf("failed to sync: %v", err)
 	}
+	idx.closeShared()
 	return idx.db.Close()
 }
-
-func (idx *Index) initDB(ctx context.Context) error {
-	// Only proceed if the database matches our application ID.
-	if err := idx.assertApplicationID(ctx); err != nil {
-		return err
+func (idx *Index) closeShared() {
+	if idx.shared == nil {
+		return
 	}
-
-	// Check if the schema is up to date.
-	userVersion, err := idx.getUserVersion(ctx)
-	if err != nil {
-		return err
-	}
-
-	if userVersion == 0 { // user version not set
-		return idx.applySchema(ctx)
-	}
-
-	if userVersion != schemaCRC {
-		dlog.Printf("user_version (%d) != schema CRC (%d)", userVersion, schemaCRC)
-		return fmt.Errorf("database does not have the correct schema")
-	}
-
-	return nil
-}
-
-func (idx *Index) applySchema(ctx context.Context) error {
-	dlog.Printf("Applying schema...")
-	schemaVersion, err := idx.getSchemaVersion(ctx)
-	if err != nil {
-		return err
-	}
-
-	if schemaVersion > 0 {
-		return fmt.Errorf("database schema_version (%d) is not zero", schemaVersion)
-	}
-
-	if err := idx.enableForeignKeys(ctx); err != nil {
-		return err
-	}
-
-	queries := schemaQueries()
-	for i, stmt := range queries {
-		_, err := idx.db.ExecContext(ctx, stmt)
-		if err != nil {
-			return fmt.Errorf("failed to apply schema query %d: %w", i+1, err)
-		}
-	}
-
-	dlog.Printf("schema CRC: %d", schemaCRC)
-	return idx.setUserVersion(ctx, schemaCRC)
-}
-
-// schemaQueries returns the individual queries in schema.sql.
-func schemaQueries() []string {
-	const numQueries = 8 // number of queries in schema.sql
-	queries := make([]string, 0, numQueries)
-	scanner := bufio.NewScanner(bytes.NewReader(_schema))
-	scanner.Split(sqlSplit)
-	for scanner.Scan() {
-		queries = append(queries, scanner.Text())
-	}
-	if err := scanner.Err(); err != nil {
-		panic(fmt.Errorf("failed to scan schema.sql: %w", err))
-	}
-	return queries
-}
-func sqlSplit(data []byte, atEOF bool) (advance int, token []byte, err error) {
-	defer func() {
-		// Trim the token of any leading or trailing whitespace.
-		token = bytes.TrimSpace(token)
-		if len(token) == 0 {
-			// Ensure we don't return an empty token.
-			token = nil
-		}
-	}()
-
-	semiColon := bytes.Index(data, []byte(";"))
-	if semiColon == -1 {
-		// No semi-colon yet...
-		if atEOF {
-			// That's everything...
-			return len(data), data, nil
-		}
-		// Ask for more data so we can find the EOL.
-		return 0, nil, nil
+	if err := idx.shared.Close(); err != nil {
+		dlog.Printf("failed to close shared index: %v", err)
 	}
-	// We found a semi-colon...
-	return semiColon + 1, data[:semiColon+1], nil
 }
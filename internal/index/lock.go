@@ -0,0 +1,112 @@
+package index
+
+import (
+	"bytes"
+	"errors"
+	"os"
+)
+
+// errLocked is returned by syncLock.tryLock if another process holds the lock.
+var errLocked = errors.New("locked by another process")
+
+// syncLock is a lock file which ensures that only one process syncs or
+// migrates an index at a time.
+//
+// Other processes keep reading the last committed snapshot of the index
+// while it is synced, which the WAL journal mode allows.
+//
+// While a sync is in progress, the lock file holds a marker, so that if the
+// process dies mid-sync, the next process to load the index can tell. See
+// interrupted.
+type syncLock struct {
+	path string
+	file *os.File
+}
+
+// newSyncLock returns the syncLock of the database at dbPath, or nil for an
+// in-memory database, which no other process can access.
+func newSyncLock(dbPath string) *syncLock {
+	file, ok := dataSourceFile(dbPath)
+	if !ok {
+		return nil
+	}
+	return &syncLock{path: file + ".lock"}
+}
+
+// tryLock acquires the lock without waiting, or returns errLocked.
+func (l *syncLock) tryLock() error { return l.acquire(false) }
+
+// lock acquires the lock, waiting for any other process to release it.
+func (l *syncLock) lock() error { return l.acquire(true) }
+
+func (l *syncLock) acquire(wait bool) error {
+	if l == nil {
+		return nil
+	}
+	f, err := os.OpenFile(l.path, os.O_CREATE|os.O_RDWR, 0644)
+	if err != nil {
+		return err
+	}
+	if err := lockFile(f, wait); err != nil {
+		f.Close()
+		return err
+	}
+	l.file = f
+	return nil
+}
+
+// unlock releases the lock, if held.
+func (l *syncLock) unlock() {
+	if l == nil || l.file == nil {
+		return
+	}
+	// Closing the file releases the lock.
+	if err := l.file.Close(); err != nil {
+		dlog.Printf("failed to unlock %q: %v", l.path, err)
+	}
+	l.file = nil
+}
+
+// syncingMarker is the content of the lock file while a sync is in progress.
+var syncingMarker = []byte("syncing\n")
+
+// markSyncing records in the lock file, which must be held, that a sync is in
+// progress.
+func (l *syncLock) markSyncing() error {
+	if l == nil || l.file == nil {
+		return nil
+	}
+	if _, err := l.file.WriteAt(syncingMarker, 0); err != nil {
+		return err
+	}
+	return l.file.Sync()
+}
+
+// clearSyncing clears the marker written by markSyncing from the lock file,
+// which must be held.
+func (l *syncLock) clearSyncing() error {
+	if l == nil || l.file == nil {
+		return nil
+	}
+	return l.file.Truncate(0)
+}
+
+// interrupted reports whether the last sync never finished, because the lock
+// file still holds the marker written by markSyncing while no process holds
+// the lock.
+func (l *syncLock) interrupted() bool {
+	if l == nil {
+		return false
+	}
+	data, err := os.ReadFile(l.path)
+	if err != nil || !bytes.Equal(data, syncingMarker) {
+		return false
+	}
+	if err := l.tryLock(); err != nil {
+		// Another process is syncing right now.
+		return false
+	}
+	defer l.unlock()
+	data, err = os.ReadFile(l.path)
+	return err == nil && bytes.Equal(data, syncingMarker)
+}
@@ -0,0 +1,9 @@
+//go:build !unix
+
+package index
+
+import "os"
+
+// lockFile is a no-op where flock is not available, so concurrent syncs rely
+// on the busy timeout of SQLite alone.
+func lockFile(*os.File, bool) error { return nil }
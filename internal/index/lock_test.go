@@ -0,0 +1,71 @@
+//go:build unix
+
+package index
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"golang.org/x/sync/errgroup"
+)
+
+func TestLoad_concurrent(t *testing.T) {
+	ctx := context.Background()
+	dbPath := dbFilePath(t)
+
+	// Every process syncs, since the sync is forced.
+	var g errgroup.Group
+	for i := 0; i < 4; i++ {
+		g.Go(func() error {
+			pkgIdx, err := Load(ctx, dbPath, testdataCodeRoots(), loadOpts(), WithForceSync())
+			if err != nil {
+				return err
+			}
+			defer pkgIdx.Close()
+			pkgs, err := pkgIdx.Search(ctx, "testdata")
+			if err != nil {
+				return err
+			}
+			require.Equal(t, []string{"aslevy.com/go-doc/testdata"}, importPaths(pkgs))
+			return nil
+		})
+	}
+	require.NoError(t, g.Wait())
+}
+
+func TestLoad_syncLocked(t *testing.T) {
+	require := require.New(t)
+	ctx := context.Background()
+	dbPath := dbFilePath(t)
+
+	load := func() *Index {
+		pkgIdx, err := Load(ctx, dbPath, testdataCodeRoots(), loadOpts())
+		require.NoError(err)
+		require.NoError(pkgIdx.waitSync())
+		t.Cleanup(func() { require.NoError(pkgIdx.Close()) })
+		return pkgIdx
+	}
+	stale := func(pkgIdx *Index) bool {
+		meta, err := pkgIdx.selectMetadata(ctx)
+		require.NoError(err)
+		return meta.GoVersion == "stale"
+	}
+	pkgIdx := load()
+	_, err := pkgIdx.db.ExecContext(ctx, `UPDATE metadata SET goVersion='stale';`)
+	require.NoError(err)
+
+	// Another process is syncing.
+	lock := newSyncLock(dbPath)
+	require.NoError(lock.tryLock())
+	require.ErrorIs(newSyncLock(dbPath).tryLock(), errLocked)
+
+	pkgIdx = load()
+	require.True(stale(pkgIdx), "the index must not be synced while locked")
+	pkgs, err := pkgIdx.Search(ctx, "testdata")
+	require.NoError(err)
+	require.Equal([]string{"aslevy.com/go-doc/testdata"}, importPaths(pkgs))
+
+	lock.unlock()
+	require.False(stale(load()))
+}
@@ -0,0 +1,243 @@
+package index
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+)
+
+// Stats describes the contents of an Index.
+type Stats struct {
+	// Path is the path to the database.
+	Path string
+
+	CreatedAt     time.Time
+	UpdatedAt     time.Time
+	BuildRevision string
+	GoVersion     string
+
+	// ResyncDue is true if the index would be synced in the auto mode.
+	ResyncDue bool
+
+	Modules []ModuleStats
+
+	// Shared describes the shared index, if any.
+	Shared *Stats
+}
+
+// ModuleStats describes a module in an Index.
+type ModuleStats struct {
+	ImportPath string
+	Version    string
+	Dir        string
+	Class      string
+	Vendor     bool
+	// Shared is true if the packages of the module are held in the shared
+	// index.
+	Shared      bool
+	NumPackages int
+	// SyncedAt is when the packages of the module were last synced, or the
+	// zero time if never.
+	SyncedAt time.Time
+}
+
+// Path returns the path to the database of the index.
+func (idx *Index) Path() string { return idx.dbPath }
+
+// Stats returns the metadata and modules of the index.
+func (idx *Index) Stats(ctx context.Context) (Stats, error) {
+	if err := idx.waitSync(); err != nil {
+		return Stats{}, err
+	}
+
+	stats := Stats{Path: idx.dbPath}
+	meta, err := idx.selectMetadata(ctx)
+	if ignoreErrNoRows(err) != nil {
+		return Stats{}, err
+	}
+	stats.CreatedAt = meta.CreatedAt
+	stats.UpdatedAt = meta.UpdatedAt
+	stats.BuildRevision = meta.BuildRevision
+	stats.GoVersion = meta.GoVersion
+
+	stats.ResyncDue, err = idx.resyncDue(ctx, idx.codeRoots)
+	if err != nil {
+		return Stats{}, err
+	}
+
+	stats.Modules, err = idx.selectModuleStats(ctx)
+	if err != nil {
+		return Stats{}, err
+	}
+
+	if idx.shared == nil {
+		return stats, nil
+	}
+	shared, err := idx.shared.Stats(ctx)
+	if err != nil {
+		return Stats{}, fmt.Errorf("failed to get shared index stats: %w", err)
+	}
+	stats.Shared = &shared
+
+	// The packages of shared modules are counted in the shared index.
+	type key struct{ importPath, version string }
+	sharedMods := make(map[key]ModuleStats, len(shared.Modules))
+	for _, mod := range shared.Modules {
+		sharedMods[key{mod.ImportPath, mod.Version}] = mod
+	}
+	for i, mod := range stats.Modules {
+		if !mod.Shared {
+			continue
+		}
+		sharedMod := sharedMods[key{mod.ImportPath, mod.Version}]
+		stats.Modules[i].NumPackages = sharedMod.NumPackages
+		stats.Modules[i].SyncedAt = sharedMod.SyncedAt
+	}
+	return stats, nil
+}
+
+func (idx *Index) selectModuleStats(ctx context.Context) ([]ModuleStats, error) {
+	const query = `
+SELECT
+  module.importPath,
+  module.version,
+  module.dir,
+  module.class,
+  module.vendor,
+  module.shared,
+  module.syncedAt,
+  (SELECT count(*) FROM package WHERE package.moduleId=module.rowid)
+FROM module
+ORDER BY
+  module.class      ASC,
+  module.importPath ASC;
+`
+	rows, err := idx.db.QueryContext(ctx, query)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var mods []ModuleStats
+	for rows.Next() {
+		var mod ModuleStats
+		var class class
+		var vendor sql.NullBool
+		var syncedAt sql.NullTime
+		if err := rows.Scan(
+			&mod.ImportPath,
+			&mod.Version,
+			&mod.Dir,
+			&class,
+			&vendor,
+			&mod.Shared,
+			&syncedAt,
+			&mod.NumPackages,
+		); err != nil {
+			return nil, err
+		}
+		mod.Class = classString(class)
+		mod.Vendor = vendor.Bool
+		mod.SyncedAt = syncedAt.Time
+		mods = append(mods, mod)
+	}
+	return mods, rows.Err()
+}
+
+// WaitSync waits for the sync started by Load, if any, to finish, since Close
+// cancels it.
+func (idx *Index) WaitSync() error { return idx.waitSync() }
+
+// Resync syncs the packages of the local modules in this process, if any have
+// been modified, created or removed since the index was synced. It is for
+// processes which keep the index open, like a daemon, and would otherwise only
+// see such changes once the index is loaded again.
+//
+// If another process is syncing the index, the last synced index is used.
+func (idx *Index) Resync(ctx context.Context) error {
+	switch idx.options.mode {
+	case ModeOff, ModeSkipSync:
+		return nil
+	}
+	if err := idx.waitSync(); err != nil {
+		dlogSync.Printf("failed to sync: %v", err)
+	}
+	changed, err := idx.localModulesChanged(ctx)
+	if err != nil || !changed {
+		return err
+	}
+
+	err = idx.lock.tryLock()
+	if errors.Is(err, errLocked) {
+		dlogSync.Printf("another process is syncing %q, using the last synced index", idx.dbPath)
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("failed to lock index: %w", err)
+	}
+	defer idx.lock.unlock()
+	return idx.syncCodeRootsLocked(ctx, idx.codeRoots)
+}
+
+// Rebuild deletes the contents of the index, and of the shared index if any,
+// and then syncs them from scratch.
+func (idx *Index) Rebuild(ctx context.Context) error {
+	if err := idx.waitSync(); err != nil {
+		return err
+	}
+	if idx.shared != nil {
+		if err := idx.shared.Rebuild(ctx); err != nil {
+			return fmt.Errorf("failed to rebuild shared index: %w", err)
+		}
+	}
+
+	if err := idx.lock.lock(); err != nil {
+		return fmt.Errorf("failed to lock index: %w", err)
+	}
+	defer idx.lock.unlock()
+
+	dlog.Printf("rebuilding %q", idx.dbPath)
+	if err := idx.deleteAll(ctx); err != nil {
+		return err
+	}
+	return idx.syncCodeRootsLocked(ctx, idx.codeRoots)
+}
+func (idx *Index) deleteAll(ctx context.Context) (retErr error) {
+	commitIfNilErr, err := idx.beginTx(ctx)
+	if err != nil {
+		return err
+	}
+	defer commitIfNilErr(&retErr)
+
+	// Deleting the modules cascades to all other tables.
+	for _, query := range []string{
+		`DELETE FROM module;`,
+		`DELETE FROM metadata;`,
+	} {
+		if _, err := idx.tx.ExecContext(ctx, query); err != nil {
+			return fmt.Errorf("failed to delete index: %w", err)
+		}
+	}
+	return nil
+}
+
+// Vacuum rebuilds the database files of the index, and of the shared index if
+// any, to reclaim unused space.
+func (idx *Index) Vacuum(ctx context.Context) error {
+	if err := idx.waitSync(); err != nil {
+		return err
+	}
+	if idx.shared != nil {
+		if err := idx.shared.Vacuum(ctx); err != nil {
+			return fmt.Errorf("failed to vacuum shared index: %w", err)
+		}
+	}
+
+	dlog.Printf("vacuuming %q", idx.dbPath)
+	if _, err := idx.db.ExecContext(ctx, `VACUUM;`); err != nil {
+		return fmt.Errorf("failed to vacuum index: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,74 @@
+package index
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"aslevy.com/go-doc/internal/godoc"
+	"aslevy.com/go-doc/internal/testutil"
+)
+
+func TestMaintain(t *testing.T) {
+	require := require.New(t)
+	ctx := context.Background()
+	dbPath := dbFilePath(t)
+
+	pkgIdx, err := Load(ctx, dbPath, testdataCodeRoots(), loadOpts())
+	require.NoError(err)
+	defer func() { require.NoError(pkgIdx.Close()) }()
+	require.Equal(dbPath, pkgIdx.Path())
+
+	stats, err := pkgIdx.Stats(ctx)
+	require.NoError(err)
+	require.Equal(dbPath, stats.Path)
+	require.False(stats.ResyncDue)
+	require.False(stats.CreatedAt.IsZero())
+	require.Len(stats.Modules, 1)
+	mod := stats.Modules[0]
+	require.Equal("aslevy.com/go-doc/testdata", mod.ImportPath)
+	require.Equal("local", mod.Class)
+	require.NotZero(mod.NumPackages)
+	require.False(mod.SyncedAt.IsZero())
+
+	require.NoError(pkgIdx.Rebuild(ctx))
+	rebuilt, err := pkgIdx.Stats(ctx)
+	require.NoError(err)
+	require.Len(rebuilt.Modules, 1)
+	require.Equal(mod.NumPackages, rebuilt.Modules[0].NumPackages)
+	require.False(rebuilt.Modules[0].SyncedAt.Before(mod.SyncedAt))
+
+	require.NoError(pkgIdx.Vacuum(ctx))
+	pkgs, err := pkgIdx.Search(ctx, "testdata")
+	require.NoError(err)
+	require.Equal([]string{"aslevy.com/go-doc/testdata"}, importPaths(pkgs))
+}
+
+func TestResync(t *testing.T) {
+	require := require.New(t)
+	ctx := context.Background()
+	root := t.TempDir()
+	testutil.WriteFiles(t, root, map[string]string{"a/a.go": "package a\n"})
+	codeRoots := []godoc.PackageDir{godoc.NewPackageDir("example.com/local", root)}
+
+	// The index is kept open, like by a daemon.
+	pkgIdx, err := Load(ctx, dbFilePath(t), codeRoots, loadOpts())
+	require.NoError(err)
+	defer func() { require.NoError(pkgIdx.Close()) }()
+	search := func() []string {
+		require.NoError(pkgIdx.Resync(ctx))
+		pkgs, err := pkgIdx.Search(ctx, "example.com/local", WithMatchPartials())
+		require.NoError(err)
+		return importPaths(pkgs)
+	}
+	require.Equal([]string{"example.com/local/a"}, search())
+
+	testutil.WriteFiles(t, root, map[string]string{"b/b.go": "package b\n"})
+	require.Equal([]string{"example.com/local/a", "example.com/local/b"}, search())
+
+	require.NoError(os.RemoveAll(filepath.Join(root, "a")))
+	require.Equal([]string{"example.com/local/b"}, search())
+}
@@ -0,0 +1,273 @@
+package index
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"embed"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// _migrations holds the SQL migrations which define the schema of the index
+// database.
+//
+// Each migration is named NNNN_<name>.sql, numbered sequentially from 0001,
+// and they are applied in order. The user_version of the database records the
+// number of migrations which have been applied.
+//
+// Migrations must never be edited once released. Instead any change to the
+// schema must be made by adding a new migration.
+//
+//go:embed migrations/*.sql
+var _migrations embed.FS
+
+type migration struct {
+	Version int
+	Name    string
+	Queries []string
+}
+
+var migrations = func() []migration {
+	entries, err := fs.ReadDir(_migrations, "migrations")
+	if err != nil {
+		panic(fmt.Errorf("failed to read migrations: %w", err))
+	}
+	migrations := make([]migration, 0, len(entries))
+	for i, entry := range entries {
+		m, err := parseMigration(entry.Name())
+		if err != nil {
+			panic(err)
+		}
+		if m.Version != i+1 {
+			panic(fmt.Errorf("migration %q is out of sequence, expected version %d", entry.Name(), i+1))
+		}
+		migrations = append(migrations, m)
+	}
+	return migrations
+}()
+
+func parseMigration(fileName string) (migration, error) {
+	version, name, found := strings.Cut(strings.TrimSuffix(fileName, ".sql"), "_")
+	if !found {
+		return migration{}, fmt.Errorf("migration %q is not named NNNN_<name>.sql", fileName)
+	}
+	v, err := strconv.Atoi(version)
+	if err != nil {
+		return migration{}, fmt.Errorf("migration %q has an invalid version: %w", fileName, err)
+	}
+	data, err := _migrations.ReadFile(path.Join("migrations", fileName))
+	if err != nil {
+		return migration{}, fmt.Errorf("failed to read migration %q: %w", fileName, err)
+	}
+	queries, err := sqlQueries(data)
+	if err != nil {
+		return migration{}, fmt.Errorf("failed to scan migration %q: %w", fileName, err)
+	}
+	return migration{Version: v, Name: name, Queries: queries}, nil
+}
+
+// sqlQueries returns the individual queries in data.
+func sqlQueries(data []byte) ([]string, error) {
+	var queries []string
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	scanner.Split(sqlSplit)
+	for scanner.Scan() {
+		queries = append(queries, scanner.Text())
+	}
+	return queries, scanner.Err()
+}
+func sqlSplit(data []byte, atEOF bool) (advance int, token []byte, err error) {
+	defer func() {
+		// Trim the token of any leading or trailing whitespace.
+		token = bytes.TrimSpace(token)
+		if len(token) == 0 {
+			// Ensure we don't return an empty token.
+			token = nil
+		}
+	}()
+
+	semiColon := bytes.Index(data, []byte(";"))
+	if semiColon == -1 {
+		// No semi-colon yet...
+		if atEOF {
+			// That's everything...
+			return len(data), data, nil
+		}
+		// Ask for more data so we can find the EOL.
+		return 0, nil, nil
+	}
+	// We found a semi-colon...
+	if !createTrigger.Match(data[:semiColon]) {
+		return semiColon + 1, data[:semiColon+1], nil
+	}
+
+	// ...but trigger bodies contain semi-colons, so the statement ends
+	// with END;
+	end := triggerEnd.FindIndex(data)
+	if end == nil {
+		if atEOF {
+			return len(data), data, nil
+		}
+		return 0, nil, nil
+	}
+	return end[1], data[:end[1]], nil
+}
+
+var (
+	createTrigger = regexp.MustCompile(`(?i)\bCREATE\s+TRIGGER\b`)
+	triggerEnd    = regexp.MustCompile(`(?i)\bEND\s*;`)
+)
+
+// errCannotMigrate indicates that the database was not created by a known
+// version of the schema, so it must be rebuilt.
+var errCannotMigrate = errors.New("database cannot be migrated")
+
+func (idx *Index) initDB(ctx context.Context) error {
+	interrupted := idx.lock.interrupted()
+	if !interrupted && idx.isMigrated(ctx) {
+		return nil
+	}
+
+	// Another process may be migrating, rebuilding or syncing the database.
+	if err := idx.lock.lock(); err != nil {
+		return fmt.Errorf("failed to lock index: %w", err)
+	}
+	defer idx.lock.unlock()
+
+	if interrupted {
+		dlog.Printf("the last sync of %q was interrupted", idx.dbPath)
+		if err := idx.quickCheck(ctx); err != nil {
+			if !isCorrupt(err) {
+				return err
+			}
+			return idx.recoverDB(ctx, err)
+		}
+		if err := idx.lock.clearSyncing(); err != nil {
+			dlog.Printf("failed to clear sync marker: %v", err)
+		}
+	}
+
+	err := idx.migrate(ctx)
+	switch {
+	case errors.Is(err, errNotIndex), isCorrupt(err):
+		return idx.recoverDB(ctx, err)
+	case !errors.Is(err, errCannotMigrate):
+		return err
+	}
+	dlog.Printf("rebuilding database: %v", err)
+	if err := idx.moveAside(".old"); err != nil {
+		return fmt.Errorf("failed to move aside database: %w", err)
+	}
+	return idx.migrate(ctx)
+}
+
+// isMigrated reports whether the database is known to have all migrations
+// applied, in which case it need not be locked to initialize it.
+func (idx *Index) isMigrated(ctx context.Context) bool {
+	if appID, err := idx.getApplicationID(ctx); err != nil || appID != sqliteApplicationID {
+		return false
+	}
+	userVersion, err := idx.getUserVersion(ctx)
+	return err == nil && userVersion == uint32(len(migrations))
+}
+
+// migrate applies any migrations which have not yet been applied to the
+// database.
+func (idx *Index) migrate(ctx context.Context) error {
+	// Only proceed if the database matches our application ID.
+	if err := idx.assertApplicationID(ctx); err != nil {
+		return err
+	}
+
+	userVersion, err := idx.getUserVersion(ctx)
+	if err != nil {
+		return err
+	}
+	if userVersion > uint32(len(migrations)) {
+		// Either the database was created by a newer version of
+		// go-doc, or prior to the use of migrations, when the
+		// user_version held a CRC of the schema.
+		return fmt.Errorf("%w: unknown user_version %d", errCannotMigrate, userVersion)
+	}
+	if userVersion == 0 {
+		schemaVersion, err := idx.getSchemaVersion(ctx)
+		if err != nil {
+			return err
+		}
+		if schemaVersion > 0 {
+			return fmt.Errorf("%w: user_version is not set but schema_version (%d) is not zero",
+				errCannotMigrate, schemaVersion)
+		}
+	}
+
+	for _, m := range migrations[userVersion:] {
+		if err := idx.applyMigration(ctx, m); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (idx *Index) applyMigration(ctx context.Context, m migration) (retErr error) {
+	dlog.Printf("applying migration %04d_%s", m.Version, m.Name)
+	tx, err := idx.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if retErr != nil {
+			tx.Rollback()
+		}
+	}()
+
+	for i, query := range m.Queries {
+		if _, err := tx.ExecContext(ctx, query); err != nil {
+			return fmt.Errorf("failed to apply migration %04d_%s query %d: %w", m.Version, m.Name, i+1, err)
+		}
+	}
+	// The user_version is updated within the transaction so that it is
+	// only updated if the migration is fully applied.
+	query := fmt.Sprintf(`PRAGMA %s=%d;`, pragmaUserVersion, m.Version)
+	if _, err := tx.ExecContext(ctx, query); err != nil {
+		return fmt.Errorf("failed to set pragma %s=%d: %w", pragmaUserVersion, m.Version, err)
+	}
+	return tx.Commit()
+}
+
+// moveAside closes the database, renames its files with suffix, and then opens
+// a new empty database at the original path.
+func (idx *Index) moveAside(suffix string) error {
+	file, ok := dataSourceFile(idx.dbPath)
+	if !ok {
+		return fmt.Errorf("%q is not a database file", idx.dbPath)
+	}
+	if err := idx.db.Close(); err != nil {
+		return err
+	}
+	for _, fileSuffix := range []string{"", "-journal", "-wal", "-shm"} {
+		err := os.Rename(file+fileSuffix, file+suffix+fileSuffix)
+		if err != nil && !os.IsNotExist(err) {
+			return err
+		}
+	}
+	dlog.Printf("moved aside %q", file)
+
+	return idx.open()
+}
+
+// dataSourceFile returns the path to the database file of the data source
+// name, if it is not an in-memory database.
+func dataSourceFile(dsn string) (string, bool) {
+	file, query, _ := strings.Cut(strings.TrimPrefix(dsn, "file:"), "?")
+	if file == "" || file == ":memory:" || strings.Contains(query, "mode=memory") {
+		return "", false
+	}
+	return file, true
+}
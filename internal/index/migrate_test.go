@@ -0,0 +1,94 @@
+package index
+
+import (
+	"context"
+	"database/sql"
+	"os"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestMigrations(t *testing.T) {
+	require.NotEmpty(t, migrations)
+	for i, m := range migrations {
+		require.Equal(t, i+1, m.Version)
+		require.NotEmpty(t, m.Queries, "migration %04d_%s has no queries", m.Version, m.Name)
+	}
+}
+
+func TestMigrate(t *testing.T) {
+	ctx := context.Background()
+	tests := []struct {
+		name  string
+		setup func(t *testing.T, db *sql.DB)
+		// movedAside is true if the database is expected to be
+		// rebuilt.
+		movedAside bool
+	}{{
+		name:  "new",
+		setup: func(*testing.T, *sql.DB) {},
+	}, {
+		name: "partially migrated",
+		setup: func(t *testing.T, db *sql.DB) {
+			idx := Index{db: db}
+			require.NoError(t, idx.setApplicationID(ctx))
+			require.NoError(t, idx.applyMigration(ctx, migrations[0]))
+		},
+	}, {
+		name: "schema CRC",
+		setup: func(t *testing.T, db *sql.DB) {
+			idx := Index{db: db}
+			require.NoError(t, idx.setApplicationID(ctx))
+			_, err := db.Exec(`CREATE TABLE metadata (rowid INTEGER PRIMARY KEY);`)
+			require.NoError(t, err)
+			require.NoError(t, idx.setUserVersion(ctx, 1348904351))
+		},
+		movedAside: true,
+	}, {
+		name: "newer version",
+		setup: func(t *testing.T, db *sql.DB) {
+			idx := Index{db: db}
+			require.NoError(t, idx.setApplicationID(ctx))
+			require.NoError(t, idx.setUserVersion(ctx, uint32(len(migrations)+1)))
+		},
+		movedAside: true,
+	}, {
+		name: "unrecognized database",
+		setup: func(t *testing.T, db *sql.DB) {
+			_, err := db.Exec(`PRAGMA application_id=1;`)
+			require.NoError(t, err)
+		},
+		movedAside: true,
+	}}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			dbPath := dbFilePath(t)
+			db, err := sql.Open("sqlite", dbPath)
+			require.NoError(t, err)
+			test.setup(t, db)
+			require.NoError(t, db.Close())
+
+			pkgIdx, err := Load(ctx, dbPath, testdataCodeRoots(), loadOpts())
+			require.NoError(t, err)
+			require.NoError(t, pkgIdx.waitSync())
+
+			userVersion, err := pkgIdx.getUserVersion(ctx)
+			require.NoError(t, err)
+			require.EqualValues(t, len(migrations), userVersion)
+
+			pkgs, err := pkgIdx.Search(ctx, "aslevy.com/go-doc/testdata")
+			require.NoError(t, err)
+			require.Len(t, pkgs, 1)
+			require.NoError(t, pkgIdx.Close())
+
+			file, _ := dataSourceFile(dbPath)
+			_, err = os.Stat(file + ".old")
+			if test.movedAside {
+				require.NoError(t, err)
+			} else {
+				require.ErrorIs(t, err, os.ErrNotExist)
+			}
+		})
+	}
+}
@@ -0,0 +1,35 @@
+CREATE TABLE symbol (
+  rowid     INTEGER PRIMARY KEY,
+  packageId INT     REFERENCES package(rowid)
+                      ON DELETE CASCADE
+                      ON UPDATE CASCADE,
+  kind      TEXT    NOT NULL, -- const, var, func, type, method, field
+  type      TEXT    NOT NULL, -- receiver or parent type of methods and fields, otherwise empty
+  name      TEXT    NOT NULL CHECK (name != ''), -- name must not be empty
+  summary   TEXT    NOT NULL, -- one-line summary of the declaration
+
+  UNIQUE(packageId, kind, type, name) ON CONFLICT IGNORE
+);
+
+CREATE INDEX symbol_idx_name ON symbol(name COLLATE NOCASE);
+
+CREATE VIEW packageSymbol AS
+  SELECT
+    symbol.rowid,
+    packageImportPath,
+    packageDir,
+    class,
+    moduleImportPath,
+    relativeNumParts,
+    relativePath,
+    kind,
+    type,
+    name,
+    summary
+  FROM symbol
+    INNER JOIN modulePackage AS package
+    ON symbol.packageId=package.rowid;
+
+-- Force a full sync so that the new table is populated for existing packages.
+DELETE FROM module;
+DELETE FROM metadata;
@@ -0,0 +1,23 @@
+-- docs holds the package and symbol doc comments for full text search.
+--
+-- Symbol docs use the rowid of the symbol, and package docs use the negated
+-- rowid of the package, so that the triggers below can efficiently remove docs
+-- along with their package or symbol.
+CREATE VIRTUAL TABLE docs USING fts5(
+  name,                -- package name, or <sym> or <type>.<method|field>
+  doc,                 -- doc comment
+  packageId UNINDEXED,
+  tokenize = 'porter unicode61'
+);
+
+CREATE TRIGGER package_delete_docs AFTER DELETE ON package BEGIN
+  DELETE FROM docs WHERE rowid = -old.rowid;
+END;
+
+CREATE TRIGGER symbol_delete_docs AFTER DELETE ON symbol BEGIN
+  DELETE FROM docs WHERE rowid = old.rowid;
+END;
+
+-- Force a full sync so that the new table is populated for existing packages.
+DELETE FROM module;
+DELETE FROM metadata;
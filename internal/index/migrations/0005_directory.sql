@@ -0,0 +1,13 @@
+-- directory records the modification time of every directory walked when
+-- syncing a module, including those without any Go files, so that later syncs
+-- only need to read the directories which have changed.
+CREATE TABLE directory (
+  rowid        INTEGER PRIMARY KEY,
+  moduleId     INT     REFERENCES module(rowid)
+                         ON DELETE CASCADE
+                         ON UPDATE CASCADE,
+  relativePath TEXT    NOT NULL,
+  modTime      INT     NOT NULL, -- unix nanoseconds
+
+  UNIQUE(moduleId, relativePath)
+);
@@ -0,0 +1,17 @@
+-- packageImport holds the import paths imported by the packages of local
+-- modules, which are used to rank search results.
+CREATE TABLE packageImport (
+  rowid      INTEGER PRIMARY KEY,
+  packageId  INT     REFERENCES package(rowid)
+                       ON DELETE CASCADE
+                       ON UPDATE CASCADE,
+  importPath TEXT    NOT NULL,
+
+  UNIQUE(packageId, importPath) ON CONFLICT IGNORE
+);
+
+CREATE INDEX packageImport_idx_importPath ON packageImport(importPath);
+
+-- Force a full sync so that the new table is populated for existing packages.
+DELETE FROM module;
+DELETE FROM metadata;
@@ -0,0 +1,2 @@
+-- syncedAt records when the packages of the module were last synced.
+ALTER TABLE module ADD COLUMN syncedAt DATETIME;
@@ -0,0 +1,76 @@
+-- name is the name declared by the package clause of each package, which may
+-- differ from the last element of its import path, as for gopkg.in/yaml.v3 or
+-- github.com/jackc/pgx/v5. Packages are also indexed as partials by their
+-- name, so that they may be found by the name they are referred to by in code.
+ALTER TABLE package ADD COLUMN name TEXT NOT NULL DEFAULT '';
+
+DROP VIEW packageSymbol;
+DROP VIEW partialPackage;
+DROP VIEW modulePackage;
+
+CREATE VIEW modulePackage AS
+  SELECT 
+    package.rowid,
+    trim(module.importPath || '/' || package.relativePath, '/') as packageImportPath,
+    rtrim(module.dir        || '/' || package.relativePath, '/') as packageDir,
+    package.moduleId,
+    module.importPath as moduleImportPath,
+    relativePath,
+    class, 
+    vendor,
+    package.numParts                   as relativeNumParts,
+    package.numParts + module.numParts as totalNumParts,
+    package.name                       as packageName
+  FROM package 
+    INNER JOIN module
+    ON package.moduleId=module.rowid 
+  ORDER BY 
+    class            ASC, 
+    moduleImportPath ASC, 
+    relativeNumParts ASC, 
+    relativePath     ASC;
+
+CREATE VIEW partialPackage AS
+  SELECT
+    package.rowid,
+    packageImportPath,
+    packageDir,
+    moduleId,
+    moduleImportPath,
+    class,
+    relativePath,
+    relativeNumParts,
+    totalNumParts,
+    parts,
+    partial.numParts as partialNumParts,
+    packageName
+  FROM partial
+    INNER JOIN modulePackage AS package
+    ON partial.packageId=package.rowid
+  ORDER BY 
+    partialNumParts  ASC,
+    class            ASC, 
+    moduleImportPath ASC,
+    relativeNumParts ASC,
+    relativePath     ASC;
+
+CREATE VIEW packageSymbol AS
+  SELECT
+    symbol.rowid,
+    packageImportPath,
+    packageDir,
+    class,
+    moduleImportPath,
+    relativeNumParts,
+    relativePath,
+    kind,
+    type,
+    name,
+    summary
+  FROM symbol
+    INNER JOIN modulePackage AS package
+    ON symbol.packageId=package.rowid;
+
+-- Force a full sync so that the names of existing packages are indexed.
+DELETE FROM module;
+DELETE FROM metadata;
@@ -0,0 +1,84 @@
+-- synopsis, isCommand and numFiles summarize each package, so that packages
+-- may be described for completion without reading their files. The synopsis is
+-- the first sentence of the package doc comment, without any "Package name"
+-- prefix.
+ALTER TABLE package ADD COLUMN synopsis  TEXT NOT NULL DEFAULT '';
+ALTER TABLE package ADD COLUMN isCommand BOOL NOT NULL DEFAULT false;
+ALTER TABLE package ADD COLUMN numFiles  INT  NOT NULL DEFAULT 0;
+
+DROP VIEW packageSymbol;
+DROP VIEW partialPackage;
+DROP VIEW modulePackage;
+
+CREATE VIEW modulePackage AS
+  SELECT 
+    package.rowid,
+    trim(module.importPath || '/' || package.relativePath, '/') as packageImportPath,
+    rtrim(module.dir        || '/' || package.relativePath, '/') as packageDir,
+    package.moduleId,
+    module.importPath as moduleImportPath,
+    relativePath,
+    class, 
+    vendor,
+    package.numParts                   as relativeNumParts,
+    package.numParts + module.numParts as totalNumParts,
+    package.name                       as packageName,
+    package.synopsis,
+    package.isCommand,
+    package.numFiles
+  FROM package 
+    INNER JOIN module
+    ON package.moduleId=module.rowid 
+  ORDER BY 
+    class            ASC, 
+    moduleImportPath ASC, 
+    relativeNumParts ASC, 
+    relativePath     ASC;
+
+CREATE VIEW partialPackage AS
+  SELECT
+    package.rowid,
+    packageImportPath,
+    packageDir,
+    moduleId,
+    moduleImportPath,
+    class,
+    relativePath,
+    relativeNumParts,
+    totalNumParts,
+    parts,
+    partial.numParts as partialNumParts,
+    packageName,
+    synopsis,
+    isCommand,
+    numFiles
+  FROM partial
+    INNER JOIN modulePackage AS package
+    ON partial.packageId=package.rowid
+  ORDER BY 
+    partialNumParts  ASC,
+    class            ASC, 
+    moduleImportPath ASC,
+    relativeNumParts ASC,
+    relativePath     ASC;
+
+CREATE VIEW packageSymbol AS
+  SELECT
+    symbol.rowid,
+    packageImportPath,
+    packageDir,
+    class,
+    moduleImportPath,
+    relativeNumParts,
+    relativePath,
+    kind,
+    type,
+    name,
+    summary
+  FROM symbol
+    INNER JOIN modulePackage AS package
+    ON symbol.packageId=package.rowid;
+
+-- Force a full sync so that the summaries of existing packages are indexed.
+DELETE FROM module;
+DELETE FROM metadata;
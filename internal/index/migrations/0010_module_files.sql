@@ -0,0 +1,5 @@
+-- moduleFiles records the content hashes of the go.mod, go.sum, go.work and
+-- vendor/modules.txt files of the local modules as of the last sync, one
+-- "<sha256> <path>" per line, so that the index is synced only when the
+-- requirements of the local modules change, not periodically.
+ALTER TABLE metadata ADD COLUMN moduleFiles TEXT NOT NULL DEFAULT '';
@@ -0,0 +1,105 @@
+-- replace records the target of the replace directive of a vendored module,
+-- as "path" or "path version", as listed in vendor/modules.txt, whose version
+-- is now recorded as well.
+--
+-- Vendored modules may then have the same import path and version as a module
+-- in the module cache, so they are now unique by vendor as well. SQLite cannot
+-- drop a UNIQUE constraint, so the module table and the views which depend on
+-- it are recreated. Deleting all modules first cascades to all other tables and
+-- forces a full sync.
+DELETE FROM module;
+DELETE FROM metadata;
+
+DROP VIEW packageSymbol;
+DROP VIEW partialPackage;
+DROP VIEW modulePackage;
+DROP TABLE module;
+
+CREATE TABLE module (
+  rowid      INTEGER  PRIMARY KEY,
+  importPath TEXT     NOT NULL,
+  version    TEXT     NOT NULL DEFAULT '', -- empty for local modules
+  replace    TEXT     NOT NULL DEFAULT '', -- empty unless vendored and replaced
+  dir        TEXT     NOT NULL CHECK (dir != ''), -- dir must not be empty
+  class      INT      NOT NULL CHECK (class >= 0 AND class <= 3), -- 0: stdlib, 1: local, 2: required, 3: not required
+  vendor     BOOL     NOT NULL DEFAULT false,
+  shared     BOOL     NOT NULL DEFAULT false, -- packages are in the shared index
+  syncedAt   DATETIME,
+  numParts   INT      GENERATED ALWAYS AS 
+                        (length(importPath) - length(replace(importPath, '/', '')) + -- number of slashes
+                          iif(length(importPath)>0,1,0)) -- add 1 if path is not empty
+                        STORED,
+
+  UNIQUE(importPath, version, vendor)
+);
+
+CREATE INDEX module_class ON module(class, importPath);
+
+CREATE VIEW modulePackage AS
+  SELECT 
+    package.rowid,
+    trim(module.importPath || '/' || package.relativePath, '/') as packageImportPath,
+    rtrim(module.dir        || '/' || package.relativePath, '/') as packageDir,
+    package.moduleId,
+    module.importPath as moduleImportPath,
+    relativePath,
+    class, 
+    vendor,
+    package.numParts                   as relativeNumParts,
+    package.numParts + module.numParts as totalNumParts,
+    package.name                       as packageName,
+    package.synopsis,
+    package.isCommand,
+    package.numFiles
+  FROM package 
+    INNER JOIN module
+    ON package.moduleId=module.rowid 
+  ORDER BY 
+    class            ASC, 
+    moduleImportPath ASC, 
+    relativeNumParts ASC, 
+    relativePath     ASC;
+
+CREATE VIEW partialPackage AS
+  SELECT
+    package.rowid,
+    packageImportPath,
+    packageDir,
+    moduleId,
+    moduleImportPath,
+    class,
+    relativePath,
+    relativeNumParts,
+    totalNumParts,
+    parts,
+    partial.numParts as partialNumParts,
+    packageName,
+    synopsis,
+    isCommand,
+    numFiles
+  FROM partial
+    INNER JOIN modulePackage AS package
+    ON partial.packageId=package.rowid
+  ORDER BY 
+    partialNumParts  ASC,
+    class            ASC, 
+    moduleImportPath ASC,
+    relativeNumParts ASC,
+    relativePath     ASC;
+
+CREATE VIEW packageSymbol AS
+  SELECT
+    symbol.rowid,
+    packageImportPath,
+    packageDir,
+    class,
+    moduleImportPath,
+    relativeNumParts,
+    relativePath,
+    kind,
+    type,
+    name,
+    summary
+  FROM symbol
+    INNER JOIN modulePackage AS package
+    ON symbol.packageId=package.rowid;
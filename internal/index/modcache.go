@@ -0,0 +1,165 @@
+package index
+
+import (
+	"context"
+	"io/fs"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	_module "golang.org/x/mod/module"
+	"golang.org/x/mod/semver"
+
+	"aslevy.com/go-doc/internal/godoc"
+)
+
+// notRequiredCodeRoots returns the code roots of the modules in the module
+// cache which are not required.
+//
+// Walking the module cache reads every directory above the module roots, which
+// is slow for a large cache, so it is only walked when the index is about to
+// sync. Otherwise the roots of the last sync are reused, and modules added to
+// the module cache since are found by the next sync.
+func (idx *Index) notRequiredCodeRoots(ctx context.Context, codeRoots []godoc.PackageDir) []godoc.PackageDir {
+	needsSync, err := idx.syncDue()
+	if err == nil && !needsSync {
+		roots, err := idx.selectNotRequiredRoots(ctx)
+		if err == nil {
+			return roots
+		}
+		dlogSync.Printf("failed to select not required modules: %v", err)
+	}
+	return modCacheCodeRoots(idx.options.modCache, codeRoots)
+}
+
+func (idx *Index) selectNotRequiredRoots(ctx context.Context) ([]godoc.PackageDir, error) {
+	const query = `
+SELECT importPath, dir FROM module WHERE class=? ORDER BY importPath;
+`
+	rows, err := idx.db.QueryContext(ctx, query, classNotRequired)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var roots []godoc.PackageDir
+	for rows.Next() {
+		var root godoc.PackageDir
+		if err := rows.Scan(&root.ImportPath, &root.Dir); err != nil {
+			return nil, err
+		}
+		roots = append(roots, root)
+	}
+	return roots, rows.Err()
+}
+
+// toolchainModule is the module of the toolchains downloaded by the go
+// command.
+const toolchainModule = "golang.org/toolchain"
+
+// modCacheCodeRoots returns the root of the latest version of every module in
+// the module cache at modCache, except for the modules of codeRoots, which
+// are required.
+//
+// Modules are extracted into directories named by their escaped module path
+// and version, e.g. github.com/!burnt!sushi/toml@v1.2.0, so only the
+// directories above the module roots are read. The toolchains downloaded by
+// the go command are modules too, but hold a GOROOT rather than packages to
+// import, so they are skipped.
+func modCacheCodeRoots(modCache string, codeRoots []godoc.PackageDir) []godoc.PackageDir {
+	required := make(map[string]bool, len(codeRoots))
+	for _, root := range codeRoots {
+		required[root.ImportPath] = true
+	}
+
+	latest := make(map[string]godoc.PackageDir)
+	versions := make(map[string]string)
+	err := filepath.WalkDir(modCache, func(dir string, d fs.DirEntry, err error) error {
+		if err != nil {
+			dlogSync.Printf("failed to read module cache: %v", err)
+			return nil
+		}
+		if !d.IsDir() || dir == modCache {
+			return nil
+		}
+		rel, err := filepath.Rel(modCache, dir)
+		if err != nil {
+			return err
+		}
+		rel = filepath.ToSlash(rel)
+		if rel == "cache" || strings.HasPrefix(d.Name(), ".") {
+			// The cache directory holds downloads, not modules.
+			return filepath.SkipDir
+		}
+		escPath, escVersion, found := strings.Cut(rel, "@")
+		if !found {
+			return nil
+		}
+		importPath, err := _module.UnescapePath(escPath)
+		if err != nil || importPath == toolchainModule {
+			return filepath.SkipDir
+		}
+		version, err := _module.UnescapeVersion(escVersion)
+		if err != nil || required[importPath] {
+			return filepath.SkipDir
+		}
+		if prev, ok := versions[importPath]; !ok || semver.Compare(version, prev) > 0 {
+			versions[importPath] = version
+			latest[importPath] = godoc.NewPackageDir(importPath, dir)
+		}
+		return filepath.SkipDir
+	})
+	if err != nil {
+		dlogSync.Printf("failed to walk module cache: %v", err)
+	}
+
+	roots := make([]godoc.PackageDir, 0, len(latest))
+	for _, root := range latest {
+		roots = append(roots, root)
+	}
+	sort.Slice(roots, func(i, j int) bool { return roots[i].ImportPath < roots[j].ImportPath })
+	return roots
+}
+
+// NotRequired reports whether the package in dir belongs to a module which is
+// not required, and is only indexed because it is in the module cache. If so,
+// the import path of the package is returned, since such modules are not code
+// roots. See WithModCache.
+func (idx *Index) NotRequired(dir string) (importPath string, notRequired bool) {
+	if idx.options.modCache == "" {
+		return "", false
+	}
+	modDir, modPath, ok := lookupModuleDir(idx.notRequiredDirs(), dir)
+	if !ok {
+		return "", false
+	}
+	rel, _ := filepath.Rel(modDir, filepath.Clean(dir))
+	return path.Join(modPath, filepath.ToSlash(rel)), true
+}
+func (idx *Index) selectNotRequiredDirs() map[string]string {
+	dirs := make(map[string]string)
+	if err := idx.waitSync(); err != nil {
+		dlog.Printf("failed to sync: %v", err)
+	}
+	const query = `
+SELECT dir, importPath FROM module WHERE class=?;
+`
+	rows, err := idx.db.Query(query, classNotRequired)
+	if err != nil {
+		dlog.Printf("failed to select not required modules: %v", err)
+		return dirs
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var dir, importPath string
+		if err := rows.Scan(&dir, &importPath); err != nil {
+			dlog.Printf("failed to scan not required module: %v", err)
+			return dirs
+		}
+		dirs[filepath.Clean(dir)] = importPath
+	}
+	if err := rows.Err(); err != nil {
+		dlog.Printf("failed to select not required modules: %v", err)
+	}
+	return dirs
+}
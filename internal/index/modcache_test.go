@@ -0,0 +1,91 @@
+package index
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"aslevy.com/go-doc/internal/godoc"
+	"github.com/stretchr/testify/require"
+)
+
+// writeModCacheModule writes a module@version with a single package named
+// client to the module cache at modCache, and returns its code root.
+func writeModCacheModule(t *testing.T, modCache, escPath, version string) string {
+	dir := filepath.Join(modCache, filepath.FromSlash(escPath)+"@"+version)
+	require.NoError(t, os.MkdirAll(filepath.Join(dir, "client"), 0755))
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "client", "client.go"), []byte("package client\n"), 0644))
+	return dir
+}
+
+func TestModCache(t *testing.T) {
+	require := require.New(t)
+	ctx := context.Background()
+
+	modCache := t.TempDir()
+	require.NoError(os.MkdirAll(filepath.Join(modCache, "cache", "download", "example.com", "old@v1.0.0"), 0755))
+	writeModCacheModule(t, modCache, "example.com/old", "v1.0.0")
+	latest := writeModCacheModule(t, modCache, "example.com/old", "v1.10.0")
+	writeModCacheModule(t, modCache, "example.com/old", "v1.9.0")
+	upper := writeModCacheModule(t, modCache, "example.com/!upper", "v0.1.0")
+	required := writeModCacheModule(t, modCache, "example.com/required", "v1.0.0")
+	writeModCacheModule(t, modCache, "example.com/required", "v1.1.0")
+	writeModCacheModule(t, modCache, "golang.org/toolchain", "v0.0.1-go1.21.0.linux-amd64")
+
+	codeRoots := append(testdataCodeRoots(), godoc.NewPackageDir("example.com/required", required))
+	roots := modCacheCodeRoots(modCache, codeRoots)
+	require.Equal([]godoc.PackageDir{
+		godoc.NewPackageDir("example.com/Upper", upper),
+		godoc.NewPackageDir("example.com/old", latest),
+	}, roots)
+
+	dbPath := dbFilePath(t)
+	pkgIdx, err := Load(ctx, dbPath, codeRoots, loadOpts(), WithModCache(modCache))
+	require.NoError(err)
+
+	// Packages of modules which are not required rank last.
+	pkgs, err := pkgIdx.Search(ctx, "client", WithMatchPartials())
+	require.NoError(err)
+	require.Equal([]string{
+		"example.com/required/client",
+		"example.com/Upper/client",
+		"example.com/old/client",
+	}, importPaths(pkgs))
+
+	importPath, notRequired := pkgIdx.NotRequired(filepath.Join(latest, "client"))
+	require.True(notRequired)
+	require.Equal("example.com/old/client", importPath)
+	_, notRequired = pkgIdx.NotRequired(filepath.Join(required, "client"))
+	require.False(notRequired)
+
+	stats, err := pkgIdx.Stats(ctx)
+	require.NoError(err)
+	classes := make(map[string]string)
+	for _, mod := range stats.Modules {
+		classes[mod.ImportPath] = mod.Class
+	}
+	require.Equal("required", classes["example.com/required"])
+	require.Equal("not required", classes["example.com/old"])
+	require.NoError(pkgIdx.Close())
+
+	// The module cache is only walked again when the index syncs.
+	writeModCacheModule(t, modCache, "example.com/new", "v1.0.0")
+	pkgIdx, err = Load(ctx, dbPath, codeRoots, loadOpts(), WithModCache(modCache))
+	require.NoError(err)
+	stats, err = pkgIdx.Stats(ctx)
+	require.NoError(err)
+	require.False(stats.ResyncDue)
+	pkgs, err = pkgIdx.Search(ctx, "client", WithMatchPartials())
+	require.NoError(err)
+	require.NotContains(importPaths(pkgs), "example.com/new/client")
+	require.Contains(importPaths(pkgs), "example.com/old/client")
+	require.NoError(pkgIdx.Close())
+
+	pkgIdx, err = Load(ctx, dbPath, codeRoots, loadOpts(), WithModCache(modCache), WithForceSync())
+	require.NoError(err)
+	defer pkgIdx.Close()
+	pkgs, err = pkgIdx.Search(ctx, "client", WithMatchPartials())
+	require.NoError(err)
+	require.Contains(importPaths(pkgs), "example.com/new/client")
+}
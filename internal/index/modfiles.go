@@ -0,0 +1,95 @@
+package index
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"maps"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+
+	"aslevy.com/go-doc/internal/godoc"
+)
+
+// moduleFiles holds the content hashes of the files which declare the
+// requirements of the local modules, keyed by their path.
+type moduleFiles map[string]string
+
+// hashModuleFiles hashes the go.mod and go.sum files of the local modules
+// among codeRoots, the vendor/modules.txt files of their vendor directories,
+// and the go.work and go.work.sum files of the workspace, if any. Files which
+// do not exist are omitted.
+func hashModuleFiles(codeRoots []godoc.PackageDir, goWork string) moduleFiles {
+	var paths []string
+	if goWork != "" {
+		paths = append(paths, goWork, goWork+".sum")
+	}
+	for _, root := range codeRoots {
+		switch class, vendor := parseClassVendor(root); {
+		case vendor:
+			paths = append(paths, filepath.Join(root.Dir, "modules.txt"))
+		case class == classLocal:
+			paths = append(paths,
+				filepath.Join(root.Dir, "go.mod"),
+				filepath.Join(root.Dir, "go.sum"))
+		}
+	}
+
+	files := make(moduleFiles, len(paths))
+	for _, path := range paths {
+		data, err := os.ReadFile(path)
+		if err != nil {
+			if !os.IsNotExist(err) {
+				dlogSync.Printf("failed to hash %s: %v", path, err)
+			}
+			continue
+		}
+		sum := sha256.Sum256(data)
+		files[path] = hex.EncodeToString(sum[:])
+	}
+	return files
+}
+
+// parseModuleFiles parses the moduleFiles column of the metadata table.
+func parseModuleFiles(s string) moduleFiles {
+	files := make(moduleFiles)
+	for _, line := range strings.Split(s, "\n") {
+		hash, path, ok := strings.Cut(line, " ")
+		if ok {
+			files[path] = hash
+		}
+	}
+	return files
+}
+
+// String formats files for the moduleFiles column of the metadata table.
+func (files moduleFiles) String() string {
+	var b strings.Builder
+	for _, path := range slices.Sorted(maps.Keys(files)) {
+		b.WriteString(files[path])
+		b.WriteByte(' ')
+		b.WriteString(path)
+		b.WriteByte('\n')
+	}
+	return b.String()
+}
+
+// changed reports whether any file was changed, created or removed since
+// last.
+func (files moduleFiles) changed(last moduleFiles) bool {
+	for path, hash := range files {
+		if last[path] != hash {
+			dlogSync.Printf("%s has changed", path)
+			return true
+		}
+	}
+	return len(files) != len(last)
+}
+
+// ModuleFilesChanged reports whether any of the module files of the code roots
+// have changed since the index was loaded. If so, the code roots may have
+// changed too, so the index should be loaded again.
+func (idx *Index) ModuleFilesChanged() bool {
+	return hashModuleFiles(idx.codeRoots, idx.options.goWork).changed(idx.loadedModuleFiles)
+}
@@ -0,0 +1,89 @@
+package index
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"aslevy.com/go-doc/internal/godoc"
+)
+
+func TestResyncDue_moduleFiles(t *testing.T) {
+	require := require.New(t)
+	ctx := context.Background()
+	dbPath := dbFilePath(t)
+	root := t.TempDir()
+	goWork := filepath.Join(t.TempDir(), "go.work")
+	writeFile := func(path, data string) {
+		require.NoError(os.WriteFile(path, []byte(data), 0644))
+	}
+	require.NoError(os.Mkdir(filepath.Join(root, "pkg"), 0755))
+	writeFile(filepath.Join(root, "pkg", "pkg.go"), "package pkg\n")
+	writeFile(filepath.Join(root, "go.mod"), "module example.com/local\n")
+	codeRoots := []godoc.PackageDir{godoc.NewPackageDir("example.com/local", root)}
+
+	// resyncDue syncs the index, if sync is true, and then reports whether
+	// another sync would be due.
+	resyncDue := func(sync bool) bool {
+		mode := ModeAutoSync
+		if !sync {
+			mode = ModeSkipSync
+		}
+		pkgIdx, err := Load(ctx, dbPath, codeRoots, loadOpts(), WithGoWork(goWork), WithMode(mode))
+		require.NoError(err)
+		defer func() { require.NoError(pkgIdx.Close()) }()
+		stats, err := pkgIdx.Stats(ctx)
+		require.NoError(err)
+		return stats.ResyncDue
+	}
+	require.False(resyncDue(true))
+
+	// Rewriting a file with the same content does not cause a sync.
+	writeFile(filepath.Join(root, "go.mod"), "module example.com/local\n")
+	require.False(resyncDue(false))
+
+	for _, path := range []string{
+		filepath.Join(root, "go.mod"),
+		filepath.Join(root, "go.sum"),
+		goWork,
+	} {
+		writeFile(path, "// changed\n")
+		require.True(resyncDue(false), path)
+		require.False(resyncDue(true), path)
+	}
+
+	require.NoError(os.Remove(goWork))
+	require.True(resyncDue(false))
+}
+
+func TestModuleFiles(t *testing.T) {
+	files := moduleFiles{"/b/go.mod": "2222", "/a/go.sum": "1111"}
+	require.Equal(t, "1111 /a/go.sum\n2222 /b/go.mod\n", files.String())
+	require.Equal(t, files, parseModuleFiles(files.String()))
+	require.Empty(t, parseModuleFiles(""))
+
+	require.False(t, files.changed(parseModuleFiles(files.String())))
+	require.True(t, files.changed(moduleFiles{"/b/go.mod": "2222"}))
+	require.True(t, files.changed(moduleFiles{"/b/go.mod": "2222", "/a/go.sum": "0000"}))
+	require.True(t, moduleFiles{}.changed(files))
+}
+
+func TestModuleFilesChanged(t *testing.T) {
+	require := require.New(t)
+	ctx := context.Background()
+	root := t.TempDir()
+	goMod := filepath.Join(root, "go.mod")
+	require.NoError(os.WriteFile(goMod, []byte("module example.com/local\n"), 0644))
+	codeRoots := []godoc.PackageDir{godoc.NewPackageDir("example.com/local", root)}
+
+	pkgIdx, err := Load(ctx, dbFilePath(t), codeRoots, loadOpts())
+	require.NoError(err)
+	defer pkgIdx.Close()
+	require.False(pkgIdx.ModuleFilesChanged())
+
+	require.NoError(os.WriteFile(goMod, []byte("module example.com/local\n\nrequire example.com/dep v1.0.0\n"), 0644))
+	require.True(pkgIdx.ModuleFilesChanged())
+}
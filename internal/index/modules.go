@@ -0,0 +1,99 @@
+package index
+
+import (
+	"path/filepath"
+
+	"aslevy.com/go-doc/internal/godoc"
+)
+
+// Module returns the module which holds the package in dir. The stdlib is
+// reported as the "std" or "cmd" module. Packages in GOPATH have no module.
+func (idx *Index) Module(dir string) (godoc.Module, bool) {
+	_, mod, ok := lookupModuleDir(idx.modules(), dir)
+	return mod, ok
+}
+
+// ModuleVersion returns the version of the module which holds the package in
+// dir, followed by its replacement, if any, as in "v1.2.3 => ../fork". The
+// version is only reported for the packages of required and not required
+// modules, whether vendored or in the module cache, since the stdlib and local
+// modules are not versioned by a go.mod file.
+func (idx *Index) ModuleVersion(dir string) (version string, ok bool) {
+	mod, ok := idx.Module(dir)
+	if !ok || mod.Version == "" {
+		return "", false
+	}
+	switch mod.Class {
+	case "stdlib", "local":
+		return "", false
+	}
+	return formatVersion(mod.Version, mod.Replace), true
+}
+
+func (idx *Index) selectModules() map[string]godoc.Module {
+	modules := make(map[string]godoc.Module)
+	if err := idx.waitSync(); err != nil {
+		dlog.Printf("failed to sync: %v", err)
+	}
+	// The root of a vendor directory is a place holder for its vendored
+	// modules, and GOPATH roots are not modules.
+	const query = `
+SELECT importPath, version, replace, dir, class, vendor FROM module
+WHERE NOT (class=? AND (vendor OR importPath=''));
+`
+	rows, err := idx.db.Query(query, classLocal)
+	if err != nil {
+		dlog.Printf("failed to select modules: %v", err)
+		return modules
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var mod godoc.Module
+		var dir string
+		var class class
+		var vendor bool
+		if err := rows.Scan(&mod.Path, &mod.Version, &mod.Replace, &dir, &class, &vendor); err != nil {
+			dlog.Printf("failed to scan module: %v", err)
+			return modules
+		}
+		mod.Class = classString(class)
+		switch {
+		case vendor:
+			mod.Class = "vendored"
+		case class == classStdlib && mod.Path == "":
+			mod.Path = "std"
+		}
+		modules[filepath.Clean(dir)] = mod
+	}
+	if err := rows.Err(); err != nil {
+		dlog.Printf("failed to select modules: %v", err)
+	}
+	return modules
+}
+
+// formatVersion returns version followed by its replacement, if any, in the
+// form used by go list -m.
+func formatVersion(version, replace string) string {
+	if replace == "" {
+		return version
+	}
+	return version + " => " + replace
+}
+
+// lookupModuleDir returns the directory of the module in modules which holds
+// dir, and its value, if any. Since modules may be nested, the closest module
+// directory above dir is used.
+func lookupModuleDir[T any](modules map[string]T, dir string) (modDir string, _ T, _ bool) {
+	dir = filepath.Clean(dir)
+	for modDir := dir; ; {
+		if v, ok := modules[modDir]; ok {
+			return modDir, v, true
+		}
+		parent := filepath.Dir(modDir)
+		if parent == modDir {
+			var zero T
+			return "", zero, false
+		}
+		modDir = parent
+	}
+}
@@ -0,0 +1,45 @@
+package index
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"aslevy.com/go-doc/internal/godoc"
+)
+
+func TestModule(t *testing.T) {
+	require := require.New(t)
+	ctx := context.Background()
+	tmp := t.TempDir()
+	local := godoc.NewPackageDir("example.com/local", filepath.Join(tmp, "local"))
+	dep := godoc.NewPackageDir("example.com/dep/v2", filepath.Join(tmp, "mod", "example.com", "dep", "v2@v2.3.1"))
+	for _, root := range []godoc.PackageDir{local, dep} {
+		dir := filepath.Join(root.Dir, "pkg")
+		require.NoError(os.MkdirAll(dir, 0755))
+		require.NoError(os.WriteFile(filepath.Join(dir, "pkg.go"), []byte("package pkg\n"), 0644))
+	}
+
+	pkgIdx, err := Load(ctx, dbFilePath(t), []godoc.PackageDir{local, dep}, loadOpts())
+	require.NoError(err)
+	defer func() { require.NoError(pkgIdx.Close()) }()
+
+	mod, ok := pkgIdx.Module(filepath.Join(local.Dir, "pkg"))
+	require.True(ok)
+	require.Equal(godoc.Module{Path: "example.com/local", Class: "local"}, mod)
+	_, ok = pkgIdx.ModuleVersion(filepath.Join(local.Dir, "pkg"))
+	require.False(ok)
+
+	mod, ok = pkgIdx.Module(filepath.Join(dep.Dir, "pkg"))
+	require.True(ok)
+	require.Equal(godoc.Module{Path: "example.com/dep/v2", Version: "v2.3.1", Class: "required"}, mod)
+	version, ok := pkgIdx.ModuleVersion(filepath.Join(dep.Dir, "pkg"))
+	require.True(ok)
+	require.Equal("v2.3.1", version)
+
+	_, ok = pkgIdx.Module(tmp)
+	require.False(ok)
+}
@@ -3,7 +3,8 @@ package index
 import (
 	"fmt"
 	"strings"
-	"time"
+
+	"aslevy.com/go-doc/internal/godoc"
 )
 
 type Mode = string
@@ -30,8 +31,18 @@ func ParseMode(s string) (Mode, error) {
 type Option func(*options)
 type options struct {
 	mode               Mode
-	resyncInterval     time.Duration
 	disableProgressBar bool
+	progress           ProgressFunc
+	detachSync         func() error
+	loadSymbols        godoc.SymbolLoader
+	sharedPath         string
+	currentDir         string
+	pins               Pins
+	modCache           string
+	goWork             string
+
+	// isShared is true for the shared index itself.
+	isShared bool
 }
 
 func newOptions(opts ...Option) options {
@@ -41,8 +52,7 @@ func newOptions(opts ...Option) options {
 }
 func defaultOptions() options {
 	return options{
-		mode:           ModeAutoSync,
-		resyncInterval: DefaultResyncInterval,
+		mode: ModeAutoSync,
 	}
 }
 
@@ -64,14 +74,93 @@ func WithMode(mode Mode) Option {
 	}
 }
 
-func WithResyncInterval(interval time.Duration) Option {
+func WithNoProgressBar() Option {
 	return func(o *options) {
-		o.resyncInterval = interval
+		o.disableProgressBar = true
 	}
 }
 
-func WithNoProgressBar() Option {
+// WithProgress causes report to be called with the progress of each sync, in
+// addition to any progress bar.
+func WithProgress(report ProgressFunc) Option {
 	return func(o *options) {
-		o.disableProgressBar = true
+		o.progress = report
+	}
+}
+
+// WithDetachedSync causes a resync in the auto mode of an index which has been
+// synced before to be left to the process started by start, see DetachedSync,
+// so that the index may be read right away, as of its last sync. If start
+// fails, the index is synced by this process as usual.
+func WithDetachedSync(start func() error) Option {
+	return func(o *options) {
+		o.detachSync = start
+	}
+}
+
+// WithGoWork causes the go.work file at path, and its go.work.sum, to be
+// hashed along with the module files of the local modules. See
+// hashModuleFiles.
+func WithGoWork(path string) Option {
+	return func(o *options) {
+		o.goWork = path
+	}
+}
+
+// WithSymbolLoader causes the exported symbols of each package to be loaded
+// with load and recorded in the index when the package is synced.
+func WithSymbolLoader(load godoc.SymbolLoader) Option {
+	return func(o *options) {
+		o.loadSymbols = load
+	}
+}
+
+// WithSharedIndex causes the packages of immutable code roots, the stdlib and
+// any module@version, to be held in the shared index at dbPath, which may be
+// used by many local indexes. The local index then only holds the packages of
+// local and vendored code roots.
+func WithSharedIndex(dbPath string) Option {
+	return func(o *options) {
+		o.sharedPath = dbPath
+	}
+}
+
+// withIsShared is used to load the shared index itself.
+func withIsShared() Option {
+	return func(o *options) {
+		o.sharedPath = ""
+		o.modCache = ""
+		o.isShared = true
+	}
+}
+
+// WithCurrentDir causes packages imported by the local package in dir to rank
+// first in search results.
+func WithCurrentDir(dir string) Option {
+	return func(o *options) {
+		o.currentDir = dir
+	}
+}
+
+// SetCurrentDir changes the directory set by WithCurrentDir, for an Index
+// which is kept open while the working directory changes.
+func (idx *Index) SetCurrentDir(dir string) { idx.options.currentDir = dir }
+
+// WithPins causes the package pinned to a search path to always rank first
+// in search results for that path.
+func WithPins(pins Pins) Option {
+	return func(o *options) {
+		o.pins = pins
+	}
+}
+
+// WithModCache causes the latest version of every module in the module cache
+// at dir, which is not already a code root, to be indexed as not required, so
+// that its docs may be read before it is required with go get. The packages
+// of such modules rank below those of all other modules. The module cache is
+// only read when the index syncs.
+func WithModCache(dir string) Option {
+	return func(o *options) {
+		o.modCache = dir
 	}
 }
@@ -0,0 +1,57 @@
+package index
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+)
+
+// PinsFile is the name of the file in the root of a module or workspace which
+// pins search paths to packages.
+const PinsFile = ".go-doc-pins"
+
+// Pins maps a search path, like "yaml", to the import path of the package
+// which it should always resolve to first, like "gopkg.in/yaml.v3".
+type Pins map[string]string
+
+// ReadPinsFile parses the pins in the file at path. It is not an error if the
+// file does not exist.
+func ReadPinsFile(path string) (Pins, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	defer f.Close()
+	pins, err := ParsePins(f)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	return pins, nil
+}
+
+// ParsePins parses pins from r, one per line, in the form:
+//
+//	<search path> <import path>
+//
+// Blank lines and lines starting with # are ignored.
+func ParsePins(r io.Reader) (Pins, error) {
+	pins := make(Pins)
+	scanner := bufio.NewScanner(r)
+	for line := 1; scanner.Scan(); line++ {
+		text := strings.TrimSpace(scanner.Text())
+		if text == "" || strings.HasPrefix(text, "#") {
+			continue
+		}
+		fields := strings.Fields(text)
+		if len(fields) != 2 {
+			return nil, fmt.Errorf("line %d: expected <search path> <import path>, got %q", line, text)
+		}
+		pins[fields[0]] = fields[1]
+	}
+	return pins, scanner.Err()
+}
@@ -23,7 +23,7 @@ func (idx *Index) assertApplicationID(ctx context.Context) error {
 		return idx.setApplicationID(ctx)
 	}
 	if appID != sqliteApplicationID {
-		return fmt.Errorf("unrecognized database")
+		return fmt.Errorf("%w: unrecognized application_id %#x", errNotIndex, appID)
 	}
 	return nil
 }
@@ -52,11 +52,6 @@ func (idx *Index) getSchemaVersion(ctx context.Context) (int, error) {
 	return schemaVersion, nil
 }
 
-func (idx *Index) enableForeignKeys(ctx context.Context) error {
-	const pragmaForeignKeys = "foreign_keys"
-	return idx.setPragma(ctx, pragmaForeignKeys, "on")
-}
-
 func (idx *Index) getPragma(ctx context.Context, key string, val any) error {
 	query := fmt.Sprintf(`PRAGMA %s;`, key)
 	err := idx.db.QueryRowContext(ctx, query).Scan(val)
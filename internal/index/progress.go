@@ -0,0 +1,174 @@
+package index
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"strings"
+	"sync"
+	"time"
+)
+
+// ProgressKind is the kind of a ProgressEvent.
+type ProgressKind = string
+
+const (
+	// ProgressStart is reported once before any code root is synced.
+	ProgressStart ProgressKind = "start"
+	// ProgressModuleStart is reported before each code root is synced.
+	ProgressModuleStart ProgressKind = "module-start"
+	// ProgressModuleEnd is reported after each code root is synced.
+	ProgressModuleEnd ProgressKind = "module-end"
+	// ProgressEnd is reported once after the sync is committed, or has
+	// failed.
+	ProgressEnd ProgressKind = "end"
+)
+
+// ProgressEvent describes the progress of a sync of an index.
+type ProgressEvent struct {
+	Kind ProgressKind `json:"event"`
+	// Shared is true for events of the shared index.
+	Shared bool `json:"shared,omitempty"`
+
+	// Module and Version identify the code root of module events.
+	Module  string `json:"module,omitempty"`
+	Version string `json:"version,omitempty"`
+
+	// Done is the number of code roots synced so far, out of Total.
+	Done  int `json:"done"`
+	Total int `json:"total"`
+
+	// Packages is the number of packages which were read and synced, for
+	// module-end events, and for all code roots, for the end event.
+	// Packages which have not changed since the last sync are not counted.
+	Packages int `json:"packages"`
+
+	// ElapsedMS is the time in milliseconds taken to sync the module, for
+	// module-end events, or all code roots, for the end event.
+	ElapsedMS int64 `json:"elapsed_ms"`
+
+	// Err is the error which failed the sync, for the end event.
+	Err string `json:"error,omitempty"`
+}
+
+// ProgressFunc is called with each ProgressEvent of a sync. See WithProgress.
+type ProgressFunc func(ProgressEvent)
+
+// The values of the -index-progress flag.
+const (
+	ProgressBar  = "bar"
+	ProgressJSON = "json"
+	ProgressOff  = "off"
+)
+
+func progressModes() string {
+	return strings.Join([]string{ProgressBar, ProgressJSON, ProgressOff}, ", ")
+}
+
+// ParseProgress parses the value of the -index-progress flag.
+func ParseProgress(s string) (string, error) {
+	switch s {
+	case ProgressBar, ProgressJSON, ProgressOff:
+		return s, nil
+	}
+	return ProgressBar, fmt.Errorf("invalid index progress: %q", s)
+}
+
+// JSONProgress returns a ProgressFunc which writes each event to w as a line
+// of JSON. It may be used by the local and the shared index at once.
+func JSONProgress(w io.Writer) ProgressFunc {
+	var mu sync.Mutex
+	enc := json.NewEncoder(w)
+	return func(event ProgressEvent) {
+		mu.Lock()
+		defer mu.Unlock()
+		if err := enc.Encode(event); err != nil {
+			dlog.Printf("failed to write progress: %v", err)
+		}
+	}
+}
+
+// syncProgress reports the progress of syncCodeRootsLocked, to the
+// ProgressFunc, if any, and to the progress bar.
+type syncProgress struct {
+	report ProgressFunc
+	pb     progressBar
+	shared bool
+
+	total, done int
+	start       time.Time
+
+	// packages is the number of packages synced so far.
+	packages int
+
+	// The current module, and the number of packages synced before it
+	// and the time at which it started.
+	module, version string
+	modulePackages  int
+	moduleStartedAt time.Time
+}
+
+func newSyncProgress(o options, total int) *syncProgress {
+	p := &syncProgress{
+		report: o.progress,
+		pb:     newProgressBar(o, total+1, "syncing code roots"),
+		shared: o.isShared,
+		total:  total,
+		start:  time.Now(),
+	}
+	p.send(ProgressEvent{Kind: ProgressStart})
+	return p
+}
+
+func (p *syncProgress) send(event ProgressEvent) {
+	if p.report == nil {
+		return
+	}
+	event.Shared = p.shared
+	event.Done = p.done
+	event.Total = p.total
+	p.report(event)
+}
+
+func (p *syncProgress) startModule(mod module) {
+	p.module, p.version = mod.ImportPath, mod.Version
+	p.modulePackages, p.moduleStartedAt = p.packages, time.Now()
+	p.send(ProgressEvent{Kind: ProgressModuleStart, Module: p.module, Version: p.version})
+}
+
+// addPackage records that a package was synced, if p is not nil.
+func (p *syncProgress) addPackage() {
+	if p != nil {
+		p.packages++
+	}
+}
+
+func (p *syncProgress) endModule() {
+	p.done++
+	p.pb.Add(1)
+	p.send(ProgressEvent{
+		Kind:      ProgressModuleEnd,
+		Module:    p.module,
+		Version:   p.version,
+		Packages:  p.packages - p.modulePackages,
+		ElapsedMS: time.Since(p.moduleStartedAt).Milliseconds(),
+	})
+}
+
+// finish reports the end of the sync, which failed if err is not nil.
+func (p *syncProgress) finish(err error) {
+	if err == nil {
+		// The last step of the progress bar is pruning and committing.
+		p.pb.Add(1)
+	}
+	p.pb.Finish()
+	event := ProgressEvent{
+		Kind:      ProgressEnd,
+		Packages:  p.packages,
+		ElapsedMS: time.Since(p.start).Milliseconds(),
+	}
+	if err != nil {
+		event.Err = err.Error()
+	}
+	p.send(event)
+}
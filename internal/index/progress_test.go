@@ -0,0 +1,79 @@
+package index
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestLoad_progress(t *testing.T) {
+	require := require.New(t)
+	ctx := context.Background()
+
+	var mu sync.Mutex
+	var events []ProgressEvent
+	progress := WithProgress(func(event ProgressEvent) {
+		mu.Lock()
+		defer mu.Unlock()
+		events = append(events, event)
+	})
+	kinds := func() []ProgressKind {
+		mu.Lock()
+		defer mu.Unlock()
+		var kinds []ProgressKind
+		for _, event := range events {
+			kinds = append(kinds, event.Kind)
+		}
+		return kinds
+	}
+
+	dbPath := dbFilePath(t)
+	pkgIdx, err := Load(ctx, dbPath, testdataCodeRoots(), loadOpts(), progress)
+	require.NoError(err)
+	require.NoError(pkgIdx.waitSync())
+	require.NoError(pkgIdx.Close())
+
+	require.Equal([]ProgressKind{ProgressStart, ProgressModuleStart, ProgressModuleEnd, ProgressEnd}, kinds())
+	end := events[len(events)-1]
+	require.Equal(1, end.Done)
+	require.Equal(1, end.Total)
+	require.Empty(end.Err)
+	require.Positive(end.Packages)
+	require.Equal(end.Packages, events[2].Packages)
+	require.Equal("aslevy.com/go-doc/testdata", events[2].Module)
+
+	// Nothing has changed, so the index is not synced again.
+	events = nil
+	pkgIdx, err = Load(ctx, dbPath, testdataCodeRoots(), loadOpts(), progress)
+	require.NoError(err)
+	require.NoError(pkgIdx.waitSync())
+	require.NoError(pkgIdx.Close())
+	require.Empty(kinds())
+}
+
+func TestJSONProgress(t *testing.T) {
+	require := require.New(t)
+	var buf bytes.Buffer
+	report := JSONProgress(&buf)
+	report(ProgressEvent{Kind: ProgressModuleEnd, Module: "example.com/m", Done: 1, Total: 2, Packages: 3, ElapsedMS: 1500})
+	report(ProgressEvent{Kind: ProgressEnd, Done: 2, Total: 2})
+
+	dec := json.NewDecoder(&buf)
+	var event map[string]any
+	require.NoError(dec.Decode(&event))
+	require.Equal(map[string]any{
+		"event":      "module-end",
+		"module":     "example.com/m",
+		"done":       1.0,
+		"total":      2.0,
+		"packages":   3.0,
+		"elapsed_ms": 1500.0,
+	}, event)
+	require.NoError(dec.Decode(&event))
+	require.Equal("end", event["event"])
+	require.False(dec.More())
+}
@@ -0,0 +1,88 @@
+package index
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"strings"
+
+	"modernc.org/sqlite"
+	sqlite3 "modernc.org/sqlite/lib"
+)
+
+// errNotIndex indicates that the database was created by another application,
+// so it must be quarantined before it can be rebuilt as an index.
+var errNotIndex = errors.New("database is not a go-doc index")
+
+// errCorrupt is returned by quickCheck if the database fails its integrity
+// check.
+var errCorrupt = errors.New("database is corrupt")
+
+// isCorrupt reports whether err indicates that the database file is corrupt,
+// truncated, or not a database at all.
+func isCorrupt(err error) bool {
+	if errors.Is(err, errCorrupt) {
+		return true
+	}
+	var sqliteErr *sqlite.Error
+	if !errors.As(err, &sqliteErr) {
+		return false
+	}
+	// Extended result codes hold the primary result code in the low byte.
+	switch sqliteErr.Code() & 0xff {
+	case sqlite3.SQLITE_CORRUPT, sqlite3.SQLITE_NOTADB:
+		return true
+	}
+	return false
+}
+
+// quickCheck runs PRAGMA quick_check on the database, and returns an error
+// wrapping errCorrupt if any problems are found.
+//
+// This is too slow to run on every load, so it is only run after a sync was
+// interrupted. See syncLock.interrupted.
+func (idx *Index) quickCheck(ctx context.Context) error {
+	dlog.Printf("checking integrity of %q", idx.dbPath)
+	rows, err := idx.db.QueryContext(ctx, `PRAGMA quick_check;`)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+	var problems []string
+	for rows.Next() {
+		var problem string
+		if err := rows.Scan(&problem); err != nil {
+			return err
+		}
+		if problem != "ok" {
+			problems = append(problems, problem)
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return err
+	}
+	if len(problems) > 0 {
+		return fmt.Errorf("%w: %s", errCorrupt, strings.Join(problems, "; "))
+	}
+	return nil
+}
+
+// recoverDB quarantines the database, which cannot be used because of cause,
+// by moving it aside, and then initializes a new database in its place to be
+// rebuilt by the next sync. The lock must be held.
+//
+// Since completion is degraded until the index is rebuilt, a warning is
+// always shown, not just when debugging.
+func (idx *Index) recoverDB(ctx context.Context, cause error) error {
+	suffix := ".corrupt"
+	if errors.Is(cause, errNotIndex) {
+		suffix = ".old"
+	}
+	file, _ := dataSourceFile(idx.dbPath)
+	log.Printf("warning: rebuilding the package index, the old one was moved to %s%s: %v", file, suffix, cause)
+	if err := idx.moveAside(suffix); err != nil {
+		return fmt.Errorf("failed to quarantine database: %w", err)
+	}
+	return idx.migrate(ctx)
+}
@@ -0,0 +1,77 @@
+package index
+
+import (
+	"bytes"
+	"context"
+	"os"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestLoad_recover(t *testing.T) {
+	ctx := context.Background()
+	tests := []struct {
+		name string
+		// setup modifies the files of a synced index.
+		setup func(t *testing.T, file string)
+		// quarantined is true if the database is expected to be moved
+		// aside and rebuilt.
+		quarantined bool
+	}{{
+		name: "not a database",
+		setup: func(t *testing.T, file string) {
+			data := bytes.Repeat([]byte("not a database\n"), 1000)
+			require.NoError(t, os.WriteFile(file, data, 0644))
+		},
+		quarantined: true,
+	}, {
+		name: "interrupted sync",
+		setup: func(t *testing.T, file string) {
+			require.NoError(t, os.WriteFile(file+".lock", syncingMarker, 0644))
+		},
+	}, {
+		name: "interrupted sync corrupted pages",
+		setup: func(t *testing.T, file string) {
+			f, err := os.OpenFile(file, os.O_RDWR, 0)
+			require.NoError(t, err)
+			info, err := f.Stat()
+			require.NoError(t, err)
+			// Keep the header page, so the database can still be
+			// opened, but overwrite the pages after it.
+			garbage := bytes.Repeat([]byte{0xff}, int(info.Size())/2)
+			_, err = f.WriteAt(garbage, info.Size()/4)
+			require.NoError(t, err)
+			require.NoError(t, f.Close())
+			require.NoError(t, os.WriteFile(file+".lock", syncingMarker, 0644))
+		},
+		quarantined: true,
+	}}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			require := require.New(t)
+			dbPath := dbFilePath(t)
+			file, _ := dataSourceFile(dbPath)
+
+			pkgIdx, err := Load(ctx, dbPath, testdataCodeRoots(), loadOpts())
+			require.NoError(err)
+			require.NoError(pkgIdx.Close())
+			test.setup(t, file)
+
+			pkgIdx, err = Load(ctx, dbPath, testdataCodeRoots(), loadOpts())
+			require.NoError(err)
+			defer pkgIdx.Close()
+			pkgs, err := pkgIdx.Search(ctx, "aslevy.com/go-doc/testdata")
+			require.NoError(err)
+			require.Len(pkgs, 1)
+
+			_, err = os.Stat(file + ".corrupt")
+			if test.quarantined {
+				require.NoError(err)
+			} else {
+				require.ErrorIs(err, os.ErrNotExist)
+			}
+			require.False(pkgIdx.lock.interrupted())
+		})
+	}
+}
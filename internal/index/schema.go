@@ -1,4 +1,5 @@
-// This file along with schema.sql define the schema for the database.
+// This file along with the migrations directory define the schema for the
+// database.
 //
 // For each SQL table there is a corresponding Go type and Index methods for
 // selecting, inserting, or updating rows.
@@ -9,60 +10,56 @@ import (
 	"bytes"
 	"context"
 	"database/sql"
-	_ "embed"
 	"fmt"
-	"hash/crc32"
 	"runtime/debug"
 	"time"
 
 	"aslevy.com/go-doc/internal/godoc"
 )
 
-// _schema is the SQL schema for the index database.
-//
-//go:embed schema.sql
-var _schema []byte
-
-var schemaCRC = func() uint32 {
-	crc := crc32.NewIEEE()
-	crc.Write(_schema)
-	return crc.Sum32()
-}()
-
 type metadata struct {
 	CreatedAt time.Time
 	UpdatedAt time.Time
 
 	BuildRevision string
 	GoVersion     string
+
+	// ModuleFiles are the hashes of the module files as of the last sync.
+	// See hashModuleFiles.
+	ModuleFiles moduleFiles
 }
 
 func (idx *Index) selectMetadata(ctx context.Context) (metadata, error) {
 	const query = `
-SELECT createdAt, updatedAt, buildRevision, goVersion FROM metadata WHERE rowid=1;
+SELECT createdAt, updatedAt, buildRevision, goVersion, moduleFiles FROM metadata WHERE rowid=1;
 `
 	return scanMetadata(idx.db.QueryRowContext(ctx, query))
 }
 func scanMetadata(row sqlRow) (metadata, error) {
 	var meta metadata
-	return meta, row.Scan(
+	var files string
+	err := row.Scan(
 		&meta.CreatedAt,
 		&meta.UpdatedAt,
 		&meta.BuildRevision,
 		&meta.GoVersion,
+		&files,
 	)
+	meta.ModuleFiles = parseModuleFiles(files)
+	return meta, err
 }
 
-func (idx *Index) upsertMetadata(ctx context.Context) error {
+func (idx *Index) upsertMetadata(ctx context.Context, files moduleFiles) error {
 	const query = `
-INSERT INTO metadata(rowid, buildRevision, goVersion) VALUES (1, ?, ?)
+INSERT INTO metadata(rowid, buildRevision, goVersion, moduleFiles) VALUES (1, ?, ?, ?)
   ON CONFLICT(rowid) DO 
     UPDATE SET 
       updatedAt=CURRENT_TIMESTAMP, 
       buildRevision=excluded.buildRevision,
-      goVersion=excluded.goVersion;
+      goVersion=excluded.goVersion,
+      moduleFiles=excluded.moduleFiles;
 `
-	if _, err := idx.tx.ExecContext(ctx, query, buildRevision, goVersion); err != nil {
+	if _, err := idx.tx.ExecContext(ctx, query, buildRevision, goVersion, files.String()); err != nil {
 		return fmt.Errorf("failed to upsert metadata: %w", err)
 	}
 	return nil
@@ -110,55 +107,87 @@ func classString(c class) string {
 type module struct {
 	ID         int64
 	ImportPath string
-	Dir        string
-	Class      class
-	Vendor     bool
+	// Version is the version of the stdlib, a module@version or a vendored
+	// module, otherwise it is empty.
+	Version string
+	// Replace is the target of the replace directive of a vendored module,
+	// if any. See vendored.Module.Replace.
+	Replace string
+	Dir     string
+	Class   class
+	Vendor  bool
+	// Shared is true if the packages of the module are held in the shared
+	// index, instead of the local index.
+	Shared bool
 }
 
-func (idx *Index) selectModule(ctx context.Context, importPath string) (module, error) {
+func (idx *Index) selectModule(ctx context.Context, importPath, version string, vendor bool) (module, error) {
 	stmt, err := idx.tx.PrepareContext(ctx, `
-SELECT rowid, importPath, dir, class, vendor FROM module WHERE importPath=?;
+SELECT rowid, importPath, version, replace, dir, class, vendor, shared FROM module WHERE importPath=? AND version=? AND vendor=?;
 `)
 	if err != nil {
 		return module{}, err
 	}
-	return scanModule(stmt.QueryRowContext(ctx, importPath))
+	return scanModule(stmt.QueryRowContext(ctx, importPath, version, vendor))
 }
 func scanModule(row sqlRow) (module, error) {
 	var mod module
-	return mod, row.Scan(&mod.ID, &mod.ImportPath, &mod.Dir, &mod.Class, &mod.Vendor)
+	return mod, row.Scan(&mod.ID, &mod.ImportPath, &mod.Version, &mod.Replace, &mod.Dir, &mod.Class, &mod.Vendor, &mod.Shared)
 }
 
 type sqlRow interface {
 	Scan(dest ...any) error
 }
 
-func (idx *Index) insertModule(ctx context.Context, pkgDir godoc.PackageDir, class class, vendor bool) (int64, error) {
+func (idx *Index) insertModule(ctx context.Context, mod module) (int64, error) {
 	stmt, err := idx.tx.PrepareContext(ctx, `
-INSERT INTO module (importPath, dir, class, vendor) VALUES (?, ?, ?, ?);
+INSERT INTO module (importPath, version, replace, dir, class, vendor, shared) VALUES (?, ?, ?, ?, ?, ?, ?);
 `)
 	if err != nil {
 		return -1, err
 	}
-	res, err := stmt.ExecContext(ctx, pkgDir.ImportPath, pkgDir.Dir, int(class), vendor)
+	res, err := stmt.ExecContext(ctx, mod.ImportPath, mod.Version, mod.Replace, mod.Dir, int(mod.Class), mod.Vendor, mod.Shared)
 	if err != nil {
-		return -1, nil
+		return -1, err
 	}
 	return res.LastInsertId()
 }
 
-func (idx *Index) updateModule(ctx context.Context, modID int64, pkgDir godoc.PackageDir, class class, vendor bool) error {
+func (idx *Index) updateModule(ctx context.Context, mod module) error {
 	stmt, err := idx.tx.PrepareContext(ctx, `
-UPDATE module SET (dir, class, vendor) = (?, ?, ?) WHERE rowid=?;
+UPDATE module SET (replace, dir, class, vendor, shared) = (?, ?, ?, ?, ?) WHERE rowid=?;
 `)
 	if err != nil {
 		return err
 	}
 
-	_, err = stmt.ExecContext(ctx, pkgDir.Dir, int(class), vendor, modID)
+	_, err = stmt.ExecContext(ctx, mod.Replace, mod.Dir, int(mod.Class), mod.Vendor, mod.Shared, mod.ID)
 	return err
 }
 
+func (idx *Index) updateModuleSyncedAt(ctx context.Context, modID int64) error {
+	stmt, err := idx.tx.PrepareContext(ctx, `
+UPDATE module SET syncedAt=CURRENT_TIMESTAMP WHERE rowid=?;
+`)
+	if err != nil {
+		return err
+	}
+	if _, err := stmt.ExecContext(ctx, modID); err != nil {
+		return fmt.Errorf("failed to update module synced at: %w", err)
+	}
+	return nil
+}
+
+// hasModule reports whether the module with the given import path and version
+// is in the index.
+func (idx *Index) hasModule(ctx context.Context, importPath, version string) (bool, error) {
+	const query = `
+SELECT EXISTS (SELECT 1 FROM module WHERE importPath=? AND version=?);
+`
+	var exists bool
+	return exists, idx.db.QueryRowContext(ctx, query, importPath, version).Scan(&exists)
+}
+
 func (idx *Index) pruneModules(ctx context.Context, keep []int64) error {
 	query := fmt.Sprintf(`
 DELETE FROM module WHERE rowid NOT IN (%s);
@@ -202,6 +231,30 @@ SELECT rowid FROM package WHERE moduleId=? AND relativePath=?;
 	return id, stmt.QueryRowContext(ctx, modID, relativePath).Scan(&id)
 }
 
+// selectPackageIDs returns the rowids of the packages of the module keyed by
+// their relative path.
+func (idx *Index) selectPackageIDs(ctx context.Context, modID int64) (map[string]int64, error) {
+	const query = `
+SELECT rowid, relativePath FROM package WHERE moduleId=?;
+`
+	rows, err := idx.tx.QueryContext(ctx, query, modID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	pkgIDs := make(map[string]int64)
+	for rows.Next() {
+		var id int64
+		var relativePath string
+		if err := rows.Scan(&id, &relativePath); err != nil {
+			return nil, err
+		}
+		pkgIDs[relativePath] = id
+	}
+	return pkgIDs, rows.Err()
+}
+
 func (idx *Index) insertPackage(ctx context.Context, modID int64, relativePath string) (int64, error) {
 	stmt, err := idx.tx.PrepareContext(ctx, `
 INSERT INTO package(moduleId, relativePath) VALUES (?, ?);
@@ -216,6 +269,45 @@ INSERT INTO package(moduleId, relativePath) VALUES (?, ?);
 	return res.LastInsertId()
 }
 
+func (idx *Index) updatePackageSummary(ctx context.Context, pkgID int64, summary godoc.PackageSummary) error {
+	stmt, err := idx.tx.PrepareContext(ctx, `
+UPDATE package SET name=?, synopsis=?, isCommand=?, numFiles=? WHERE rowid=?;
+`)
+	if err != nil {
+		return err
+	}
+	if _, err := stmt.ExecContext(ctx,
+		summary.Name,
+		summary.Synopsis,
+		summary.IsCommand,
+		summary.NumFiles,
+		pkgID,
+	); err != nil {
+		return fmt.Errorf("failed to update package summary: %w", err)
+	}
+	return nil
+}
+
+func (idx *Index) deleteModulePackages(ctx context.Context, modID int64) error {
+	const query = `
+DELETE FROM package WHERE moduleId=?;
+`
+	if _, err := idx.tx.ExecContext(ctx, query, modID); err != nil {
+		return fmt.Errorf("failed to delete module packages: %w", err)
+	}
+	return nil
+}
+
+func (idx *Index) deletePackage(ctx context.Context, pkgID int64) error {
+	const query = `
+DELETE FROM package WHERE rowid=?;
+`
+	if _, err := idx.tx.ExecContext(ctx, query, pkgID); err != nil {
+		return fmt.Errorf("failed to delete package: %w", err)
+	}
+	return nil
+}
+
 func (idx *Index) prunePackages(ctx context.Context, modID int64, keep []int64) error {
 	dlog.Printf("pruning unused packages for module %d", modID)
 	query := fmt.Sprintf(`
@@ -236,6 +328,107 @@ func prunePackagesArgs(modID int64, keep []int64) []any {
 	return args
 }
 
+type directory struct {
+	ID           int64
+	ModuleID     int64
+	RelativePath string
+	// ModTime is the modification time of the directory in unix
+	// nanoseconds. In local modules, it is the latest modification time of
+	// the directory and its .go files. See localDirModTime.
+	ModTime int64
+}
+
+// selectDirectories returns the directories of the module keyed by their
+// relative path.
+func (idx *Index) selectDirectories(ctx context.Context, modID int64) (map[string]directory, error) {
+	const query = `
+SELECT rowid, moduleId, relativePath, modTime FROM directory WHERE moduleId=?;
+`
+	rows, err := idx.tx.QueryContext(ctx, query, modID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	dirs := make(map[string]directory)
+	for rows.Next() {
+		var dir directory
+		if err := rows.Scan(&dir.ID, &dir.ModuleID, &dir.RelativePath, &dir.ModTime); err != nil {
+			return nil, err
+		}
+		dirs[dir.RelativePath] = dir
+	}
+	return dirs, rows.Err()
+}
+
+// selectLocalDirectories calls handler with the full path and modification
+// time of every directory of the local modules.
+func (idx *Index) selectLocalDirectories(ctx context.Context, handler func(dir string, modTime int64) error) error {
+	const query = `
+SELECT
+  rtrim(module.dir || '/' || directory.relativePath, '/'),
+  directory.modTime
+FROM directory
+  INNER JOIN module
+  ON directory.moduleId=module.rowid
+WHERE module.class=? AND NOT module.vendor;
+`
+	rows, err := idx.db.QueryContext(ctx, query, classLocal)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var dir string
+		var modTime int64
+		if err := rows.Scan(&dir, &modTime); err != nil {
+			return err
+		}
+		if err := handler(dir, modTime); err != nil {
+			return err
+		}
+	}
+	return rows.Err()
+}
+
+func (idx *Index) upsertDirectory(ctx context.Context, modID int64, relativePath string, modTime int64) (int64, error) {
+	stmt, err := idx.tx.PrepareContext(ctx, `
+INSERT INTO directory(moduleId, relativePath, modTime) VALUES (?, ?, ?)
+  ON CONFLICT(moduleId, relativePath) DO
+    UPDATE SET modTime=excluded.modTime
+  RETURNING rowid;
+`)
+	if err != nil {
+		return -1, err
+	}
+	var id int64
+	if err := stmt.QueryRowContext(ctx, modID, relativePath, modTime).Scan(&id); err != nil {
+		return -1, fmt.Errorf("failed to upsert directory: %w", err)
+	}
+	return id, nil
+}
+
+func (idx *Index) deleteModuleDirectories(ctx context.Context, modID int64) error {
+	const query = `
+DELETE FROM directory WHERE moduleId=?;
+`
+	if _, err := idx.tx.ExecContext(ctx, query, modID); err != nil {
+		return fmt.Errorf("failed to delete module directories: %w", err)
+	}
+	return nil
+}
+
+func (idx *Index) pruneDirectories(ctx context.Context, modID int64, keep []int64) error {
+	query := fmt.Sprintf(`
+DELETE FROM directory WHERE moduleId=? AND rowid NOT IN (%s);
+`, placeholders(len(keep)))
+	_, err := idx.tx.ExecContext(ctx, query, prunePackagesArgs(modID, keep)...)
+	if err != nil {
+		return fmt.Errorf("failed to prune directories: %w", err)
+	}
+	return nil
+}
+
 type partial struct {
 	ID        int64
 	PackageID int64
@@ -258,6 +451,65 @@ INSERT INTO partial(packageId, parts) VALUES (?, ?);
 	return res.LastInsertId()
 }
 
+func (idx *Index) insertPackageImports(ctx context.Context, pkgID int64, imports []string) error {
+	if len(imports) == 0 {
+		return nil
+	}
+	stmt, err := idx.tx.PrepareContext(ctx, `
+INSERT INTO packageImport(packageId, importPath) VALUES (?, ?);
+`)
+	if err != nil {
+		return err
+	}
+	for _, importPath := range imports {
+		if _, err := stmt.ExecContext(ctx, pkgID, importPath); err != nil {
+			return fmt.Errorf("failed to insert package import: %w", err)
+		}
+	}
+	return nil
+}
+
+type symbol struct {
+	ID        int64
+	PackageID int64
+	godoc.Symbol
+}
+
+func (idx *Index) insertSymbol(ctx context.Context, pkgID int64, sym godoc.Symbol) (int64, error) {
+	stmt, err := idx.tx.PrepareContext(ctx, `
+INSERT INTO symbol(packageId, kind, type, name, summary) VALUES (?, ?, ?, ?, ?);
+`)
+	if err != nil {
+		return -1, err
+	}
+
+	res, err := stmt.ExecContext(ctx, pkgID, sym.Kind, sym.Type, sym.Name, sym.Summary)
+	if err != nil {
+		return -1, fmt.Errorf("failed to insert symbol: %w", err)
+	}
+	if n, err := res.RowsAffected(); err != nil || n == 0 {
+		// The symbol is a duplicate and was ignored.
+		return -1, err
+	}
+	return res.LastInsertId()
+}
+
+// insertDocs inserts the doc for full text search. The docID is the rowid of
+// the symbol, or the negated rowid of the package for package docs.
+func (idx *Index) insertDocs(ctx context.Context, docID, pkgID int64, name, doc string) error {
+	stmt, err := idx.tx.PrepareContext(ctx, `
+INSERT INTO docs(rowid, name, doc, packageId) VALUES (?, ?, ?, ?);
+`)
+	if err != nil {
+		return err
+	}
+
+	if _, err := stmt.ExecContext(ctx, docID, name, doc, pkgID); err != nil {
+		return fmt.Errorf("failed to insert docs: %w", err)
+	}
+	return nil
+}
+
 type sqlTx struct {
 	*sql.Tx
 	stmts map[string]*sql.Stmt
@@ -15,6 +15,9 @@ type SearchOption func(*searchOptions)
 
 type searchOptions struct {
 	matchPartials bool
+
+	highlightOpen, highlightClose string
+	limit                         int
 }
 
 func newSearchOptions(opts ...SearchOption) searchOptions {
@@ -35,6 +38,23 @@ func WithMatchPartials() SearchOption {
 	}
 }
 
+// WithHighlight causes SearchDocs to enclose matching terms in snippets with
+// open and close.
+func WithHighlight(open, close string) SearchOption {
+	return func(o *searchOptions) {
+		o.highlightOpen = open
+		o.highlightClose = close
+	}
+}
+
+// WithLimit limits the number of results returned by SearchDocs. A limit less
+// than one means no limit.
+func WithLimit(limit int) SearchOption {
+	return func(o *searchOptions) {
+		o.limit = limit
+	}
+}
+
 func (idx *Index) Search(ctx context.Context, path string, opts ...SearchOption) ([]godoc.PackageDir, error) {
 	rows, err := idx.searchRows(ctx, path, opts...)
 	if err != nil {
@@ -45,28 +65,37 @@ func (idx *Index) Search(ctx context.Context, path string, opts ...SearchOption)
 	}
 
 	var pkgs []godoc.PackageDir
-	return pkgs, scanPackageDirs(rows, func(pkg godoc.PackageDir) error {
+	return pkgs, scanPackageDirs(rows, func(pkg godoc.PackageDir, _ godoc.PackageSummary) error {
 		pkgs = append(pkgs, pkg)
 		return nil
 	})
 }
-func scanPackageDirs(rows *sql.Rows, handler func(godoc.PackageDir) error) error {
+
+// scanPackageDirs calls handler with each package and its summary.
+func scanPackageDirs(rows *sql.Rows, handler func(pkg godoc.PackageDir, summary godoc.PackageSummary) error) error {
 	defer rows.Close()
 	for rows.Next() {
-		pkg, err := scanPackageDir(rows)
+		pkg, summary, err := scanPackageDir(rows)
 		if err != nil {
 			return err
 		}
-		if err := handler(pkg); err != nil {
+		if err := handler(pkg, summary); err != nil {
 			return err
 		}
 	}
 	return rows.Err()
 }
-func scanPackageDir(row sqlRow) (godoc.PackageDir, error) {
-	var pkg godoc.PackageDir
+func scanPackageDir(row sqlRow) (pkg godoc.PackageDir, summary godoc.PackageSummary, _ error) {
 	var min int
-	return pkg, row.Scan(&pkg.ImportPath, &pkg.Dir, &min)
+	return pkg, summary, row.Scan(
+		&pkg.ImportPath,
+		&pkg.Dir,
+		&min,
+		&summary.Name,
+		&summary.Synopsis,
+		&summary.IsCommand,
+		&summary.NumFiles,
+	)
 }
 
 func (idx *Index) searchRows(ctx context.Context, path string, opts ...SearchOption) (*sql.Rows, error) {
@@ -88,24 +117,58 @@ func (idx *Index) searchQueryParams(ctx context.Context, path string, opts ...Se
 	if err != nil {
 		return "", nil, err
 	}
+	pinned, pinnedParams := idx.searchOrderPinned(path)
+	params = append(params, pinnedParams...)
+	params = append(params, idx.options.currentDir)
 
+	// Packages of modules which are not required rank last. Otherwise,
+	// packages imported by the package in the current directory rank
+	// first, followed by those imported anywhere in the local modules.
 	const selectQuery = `
 SELECT 
   packageImportPath, 
   packageDir, 
-  min(partialNumParts) 
+  min(partialNumParts),
+  packageName,
+  synopsis,
+  isCommand,
+  numFiles
 FROM 
   partialPackage
 WHERE %s
 GROUP BY packageImportPath
-ORDER BY 
+ORDER BY %s
+  class = %d       ASC,
   partialNumParts  ASC,
+  CASE
+    WHEN packageImportPath IN (
+      SELECT importPath FROM packageImport
+        INNER JOIN main.modulePackage AS package
+        ON packageImport.packageId=package.rowid
+      WHERE packageDir = ?
+    ) THEN 0
+    WHEN packageImportPath IN (
+      SELECT importPath FROM packageImport
+    ) THEN 1
+    ELSE 2
+  END              ASC,
   class            ASC, 
   moduleImportPath ASC,
   relativeNumParts ASC,
   relativePath     ASC;
 `
-	return fmt.Sprintf(selectQuery, where), params, err
+	return fmt.Sprintf(selectQuery, where, pinned, classNotRequired), params, err
+}
+
+// searchOrderPinned returns the ORDER BY term which ranks the package pinned
+// to path first, if any.
+func (idx *Index) searchOrderPinned(path string) (order string, params []any) {
+	pinned, ok := idx.options.pins[path]
+	if !ok {
+		return "", nil
+	}
+	return `
+  packageImportPath = ? DESC,`, []any{pinned}
 }
 func (idx *Index) searchWhereParams(ctx context.Context, path string, opts ...SearchOption) (where string, params []any, _ error) {
 	o := newSearchOptions(opts...)
@@ -185,8 +248,20 @@ func searchLike(path string) (numParts int, like *strings.Builder) {
 	}
 	return numParts, like
 }
+
+// maxPartialNumParts returns the greatest number of parts of any partial,
+// including those of the shared index, or zero if there are none, as is the
+// case when every package is held in the shared index.
 func (idx *Index) maxPartialNumParts(ctx context.Context) (int, error) {
-	const query = `SELECT MAX(numParts) FROM partial;`
+	query := `SELECT COALESCE(MAX(numParts), 0) FROM main.partial;`
+	if idx.shared != nil {
+		query = `
+SELECT COALESCE(MAX(numParts), 0) FROM (
+  SELECT numParts FROM main.partial
+  UNION ALL
+  SELECT numParts FROM shared.partial
+);`
+	}
 	var max int
 	return max, idx.db.QueryRowContext(ctx, query).Scan(&max)
 }
@@ -6,11 +6,14 @@ import (
 	"flag"
 	"fmt"
 	"go/build"
+	"os"
 	"path/filepath"
+	"strings"
 	"testing"
 
 	"aslevy.com/go-doc/internal/benchmark"
 	"aslevy.com/go-doc/internal/godoc"
+	"aslevy.com/go-doc/internal/testutil"
 	"github.com/stretchr/testify/require"
 )
 
@@ -278,3 +281,186 @@ func scanImportPath(rows sqlRow) (string, error) {
 	var path string
 	return path, rows.Scan(&path)
 }
+
+func TestSearchRank(t *testing.T) {
+	ctx := context.Background()
+	root := t.TempDir()
+	writePkg := func(dir, name string, imports ...string) {
+		dir = filepath.Join(root, filepath.FromSlash(dir))
+		require.NoError(t, os.MkdirAll(dir, 0755))
+		src := "package " + name + "\n"
+		for _, importPath := range imports {
+			src += fmt.Sprintf("import _ %q\n", importPath)
+		}
+		require.NoError(t, os.WriteFile(filepath.Join(dir, name+".go"), []byte(src), 0644))
+	}
+	writePkg("x/errors", "errors")
+	writePkg("y/errors", "errors")
+	writePkg("z/errors", "errors")
+	writePkg("cmd/main", "main", "example.com/app/z/errors")
+	writePkg("other", "other", "example.com/app/y/errors")
+	codeRoots := []godoc.PackageDir{godoc.NewPackageDir("example.com/app", root)}
+
+	tests := []struct {
+		name    string
+		opts    []Option
+		results []string
+	}{{
+		name: "imported by module",
+		results: []string{
+			"example.com/app/y/errors",
+			"example.com/app/z/errors",
+			"example.com/app/x/errors",
+		},
+	}, {
+		name: "imported by current dir",
+		opts: []Option{WithCurrentDir(filepath.Join(root, "cmd", "main"))},
+		results: []string{
+			"example.com/app/z/errors",
+			"example.com/app/y/errors",
+			"example.com/app/x/errors",
+		},
+	}, {
+		name: "pinned",
+		opts: []Option{
+			WithCurrentDir(filepath.Join(root, "cmd", "main")),
+			WithPins(Pins{"errors": "example.com/app/x/errors"}),
+		},
+		results: []string{
+			"example.com/app/x/errors",
+			"example.com/app/z/errors",
+			"example.com/app/y/errors",
+		},
+	}}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			pkgIdx, err := Load(ctx, dbMem, codeRoots, loadOpts(), WithOptions(test.opts...))
+			require.NoError(t, err)
+			t.Cleanup(func() { require.NoError(t, pkgIdx.Close()) })
+
+			pkgs, err := pkgIdx.Search(ctx, "errors", WithMatchPartials())
+			require.NoError(t, err)
+			require.Equal(t, test.results, importPaths(pkgs))
+		})
+	}
+}
+
+func TestSearchPackageName(t *testing.T) {
+	ctx := context.Background()
+	root := t.TempDir()
+	writePkg := func(dir, name string) {
+		dir = filepath.Join(root, filepath.FromSlash(dir))
+		require.NoError(t, os.MkdirAll(dir, 0755))
+		require.NoError(t, os.WriteFile(filepath.Join(dir, name+".go"), []byte("package "+name+"\n"), 0644))
+	}
+	writePkg("yaml.v3", "yaml")
+	writePkg("go-humanize", "humanize")
+	writePkg("pgx/v5", "pgx")
+	writePkg("cmd/tool", "main")
+	codeRoots := []godoc.PackageDir{godoc.NewPackageDir("example.com/app", root)}
+
+	pkgIdx, err := Load(ctx, dbMem, codeRoots, loadOpts())
+	require.NoError(t, err)
+	t.Cleanup(func() { require.NoError(t, pkgIdx.Close()) })
+
+	for path, want := range map[string][]string{
+		"yaml":      {"example.com/app/yaml.v3"},
+		"yaml.v3":   {"example.com/app/yaml.v3"},
+		"humanize":  {"example.com/app/go-humanize"},
+		"pgx":       {"example.com/app/pgx/v5"},
+		"app/pgx":   {"example.com/app/pgx/v5"},
+		"pgx/v5":    {"example.com/app/pgx/v5"},
+		"main":      {},
+		"cmd/tool":  {"example.com/app/cmd/tool"},
+		"app/main":  {},
+		"app/yaml":  {"example.com/app/yaml.v3"},
+		"humanize2": {},
+	} {
+		pkgs, err := pkgIdx.Search(ctx, path)
+		require.NoError(t, err)
+		require.Equal(t, want, importPaths(pkgs), path)
+	}
+
+	dirs := NewDirs(pkgIdx).(*Dirs)
+	require.NoError(t, dirs.FilterPartial("humaniz"))
+	pkg, ok := dirs.Next()
+	require.True(t, ok)
+	require.Equal(t, "humanize", dirs.PackageName(pkg.Dir))
+}
+
+func TestSearchPackageSummary(t *testing.T) {
+	ctx := context.Background()
+	root := t.TempDir()
+	testutil.WriteFiles(t, root, map[string]string{
+		"pretty/doc.go":        "// Package pretty prints values. It is pretty.\npackage pretty\n",
+		"pretty/print.go":      "package pretty\n",
+		"pretty/print_test.go": "package pretty\n",
+		"cmd/tool/main.go":     "package main\n",
+		// The package fails to load, so its summary is not known.
+		"broken/a.go": "package a\n",
+		"broken/b.go": "package b\n",
+	})
+	codeRoots := []godoc.PackageDir{godoc.NewPackageDir("example.com/app", root)}
+
+	pkgIdx, err := Load(ctx, dbMem, codeRoots, loadOpts())
+	require.NoError(t, err)
+	t.Cleanup(func() { require.NoError(t, pkgIdx.Close()) })
+
+	dirs := NewDirs(pkgIdx).(*Dirs)
+	require.NoError(t, dirs.FilterPartial("example.com/app"))
+	summaries := make(map[string]godoc.PackageSummary)
+	var unknown []string
+	for pkg, ok := dirs.Next(); ok; pkg, ok = dirs.Next() {
+		summary, ok := dirs.PackageSummary(pkg.Dir)
+		if !ok {
+			unknown = append(unknown, pkg.ImportPath)
+			continue
+		}
+		summaries[pkg.ImportPath] = summary
+	}
+	require.Equal(t, []string{"example.com/app/broken"}, unknown)
+	require.Equal(t, map[string]godoc.PackageSummary{
+		"example.com/app/pretty": {
+			Name:     "pretty",
+			Synopsis: "prints values",
+			NumFiles: 2,
+		},
+		"example.com/app/cmd/tool": {
+			Name:      "main",
+			IsCommand: true,
+			NumFiles:  1,
+		},
+	}, summaries)
+}
+
+func TestNamePartials(t *testing.T) {
+	for _, test := range []struct {
+		importPath, name string
+		partials         []string
+	}{
+		{"net/http", "http", nil},
+		{"gopkg.in/yaml.v3", "yaml", []string{"yaml", "gopkg.in/yaml"}},
+		{"github.com/jackc/pgx/v5", "pgx", []string{"pgx", "jackc/pgx", "github.com/jackc/pgx"}},
+		{"github.com/dustin/go-humanize", "humanize", []string{"humanize", "dustin/humanize", "github.com/dustin/humanize"}},
+		{"math/rand/v2", "rand", []string{"rand", "math/rand"}},
+		{"cmd/go", "main", nil},
+	} {
+		require.Equal(t, test.partials, namePartials(test.importPath, test.name), test.importPath)
+	}
+}
+
+func TestParsePins(t *testing.T) {
+	pins, err := ParsePins(strings.NewReader(`
+# comment
+yaml  gopkg.in/yaml.v3
+errors github.com/pkg/errors
+`))
+	require.NoError(t, err)
+	require.Equal(t, Pins{
+		"yaml":   "gopkg.in/yaml.v3",
+		"errors": "github.com/pkg/errors",
+	}, pins)
+
+	_, err = ParsePins(strings.NewReader("yaml\n"))
+	require.Error(t, err)
+}
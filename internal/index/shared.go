@@ -0,0 +1,90 @@
+package index
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	_ "embed"
+	"fmt"
+
+	"modernc.org/sqlite"
+
+	"aslevy.com/go-doc/internal/godoc"
+)
+
+// _sharedViews are the TEMP views which include the packages of the shared
+// index in queries against a local index.
+//
+//go:embed shared.sql
+var _sharedViews []byte
+
+var sharedViewQueries = func() []string {
+	queries, err := sqlQueries(_sharedViews)
+	if err != nil {
+		panic(fmt.Errorf("failed to scan shared.sql: %w", err))
+	}
+	return queries
+}()
+
+// sharedCodeRoots returns the immutable code roots, which are held in the
+// shared index.
+func sharedCodeRoots(codeRoots []godoc.PackageDir) []godoc.PackageDir {
+	var shared []godoc.PackageDir
+	for _, root := range codeRoots {
+		if isImmutable(parseModule(root)) {
+			shared = append(shared, root)
+		}
+	}
+	return shared
+}
+
+// attachShared reopens idx.db so that every connection has the shared index
+// attached, along with the TEMP views which include its packages.
+//
+// This must only be done after the database has been migrated, as the TEMP
+// views shadow the views of the same name in the main schema.
+func (idx *Index) attachShared() error {
+	if err := idx.db.Close(); err != nil {
+		return err
+	}
+
+	// ATTACH and TEMP views are per connection, and database/sql may open
+	// multiple connections, so they must be set up as each connection is
+	// opened.
+	//
+	// The shared index is only written by idx.shared, over its own
+	// connections, so it is attached read-only. Otherwise the immediate
+	// write transactions of the local index would also lock the shared
+	// index, and wait for, or hold up, syncs of the shared index by other
+	// processes.
+	drv := &sqlite.Driver{}
+	sharedFile, _ := dataSourceFile(idx.shared.dbPath)
+	sharedPath := "file:" + sharedFile + "?mode=ro"
+	drv.RegisterConnectionHook(func(conn sqlite.ExecQuerierContext, _ string) error {
+		return attachSharedConn(conn, sharedPath)
+	})
+	idx.db = sql.OpenDB(connector{driver: drv, dsn: dataSourceName(idx.dbPath)})
+	return nil
+}
+
+func attachSharedConn(conn sqlite.ExecQuerierContext, sharedPath string) error {
+	ctx := context.Background()
+	args := []driver.NamedValue{{Ordinal: 1, Value: sharedPath}}
+	if _, err := conn.ExecContext(ctx, `ATTACH DATABASE ? AS shared;`, args); err != nil {
+		return fmt.Errorf("failed to attach shared index: %w", err)
+	}
+	for i, query := range sharedViewQueries {
+		if _, err := conn.ExecContext(ctx, query, nil); err != nil {
+			return fmt.Errorf("failed to create shared view %d: %w", i+1, err)
+		}
+	}
+	return nil
+}
+
+type connector struct {
+	driver driver.Driver
+	dsn    string
+}
+
+func (c connector) Connect(context.Context) (driver.Conn, error) { return c.driver.Open(c.dsn) }
+func (c connector) Driver() driver.Driver                        { return c.driver }
@@ -0,0 +1,84 @@
+-- These TEMP views are created on every connection to a local index which has
+-- the shared index attached as the "shared" schema.
+--
+-- They shadow the views of the same name in the main schema so that queries
+-- against them include the packages of the shared modules used by the local
+-- index. The columns must match those of the views in the main schema.
+
+CREATE TEMP VIEW sharedModule AS
+  SELECT
+    shared.module.rowid,
+    main.module.class
+  FROM shared.module
+    INNER JOIN main.module
+    ON main.module.shared AND
+       main.module.importPath=shared.module.importPath AND
+       main.module.version=shared.module.version;
+
+-- sharedModulePackage is the shared half of modulePackage.
+CREATE TEMP VIEW sharedModulePackage AS
+  SELECT
+    package.rowid,
+    packageImportPath,
+    packageDir,
+    moduleId,
+    moduleImportPath,
+    relativePath,
+    module.class,
+    vendor,
+    relativeNumParts,
+    totalNumParts,
+    packageName,
+    synopsis,
+    isCommand,
+    numFiles
+  FROM shared.modulePackage AS package
+    INNER JOIN sharedModule AS module
+    ON package.moduleId=module.rowid;
+
+CREATE TEMP VIEW modulePackage AS
+  SELECT * FROM main.modulePackage
+  UNION ALL
+  SELECT * FROM sharedModulePackage;
+
+CREATE TEMP VIEW partialPackage AS
+  SELECT * FROM main.partialPackage
+  UNION ALL
+  SELECT
+    package.rowid,
+    packageImportPath,
+    packageDir,
+    moduleId,
+    moduleImportPath,
+    class,
+    relativePath,
+    relativeNumParts,
+    totalNumParts,
+    parts,
+    partial.numParts as partialNumParts,
+    packageName,
+    synopsis,
+    isCommand,
+    numFiles
+  FROM shared.partial
+    INNER JOIN sharedModulePackage AS package
+    ON partial.packageId=package.rowid;
+
+CREATE TEMP VIEW packageSymbol AS
+  SELECT * FROM main.packageSymbol
+  UNION ALL
+  SELECT
+    symbol.rowid,
+    packageImportPath,
+    packageDir,
+    class,
+    moduleImportPath,
+    relativeNumParts,
+    relativePath,
+    kind,
+    type,
+    name,
+    summary
+  FROM shared.symbol
+    INNER JOIN sharedModulePackage AS package
+    ON symbol.packageId=package.rowid;
@@ -0,0 +1,146 @@
+package index
+
+import (
+	"context"
+	"database/sql"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"aslevy.com/go-doc/internal/godoc"
+	"github.com/stretchr/testify/require"
+)
+
+// sharedTestModule writes a module@version to a temporary module cache and
+// returns its code root.
+func sharedTestModule(t *testing.T) godoc.PackageDir {
+	dir := filepath.Join(t.TempDir(), "example.com", "shared@v1.0.0")
+	require.NoError(t, os.MkdirAll(filepath.Join(dir, "client"), 0755))
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "client", "client.go"), []byte(`// Package client talks to the shared service.
+package client
+
+// NewClient returns a client for the shared service.
+func NewClient() {}
+`), 0644))
+	return godoc.NewPackageDir("example.com/shared", dir)
+}
+
+func TestSharedIndex(t *testing.T) {
+	ctx := context.Background()
+	tmp := t.TempDir()
+	sharedPath := filepath.Join(tmp, "shared.sqlite3")
+	codeRoots := append(testdataCodeRoots(), sharedTestModule(t))
+
+	load := func(t *testing.T, name string) *Index {
+		pkgIdx, err := Load(ctx, filepath.Join(tmp, name), codeRoots, loadOpts(),
+			WithSymbolLoader(testSymbols),
+			WithSharedIndex(sharedPath),
+		)
+		require.NoError(t, err)
+		t.Cleanup(func() { require.NoError(t, pkgIdx.Close()) })
+		return pkgIdx
+	}
+	// Two local indexes share the packages of the same module@version.
+	for _, name := range []string{"a.sqlite3", "b.sqlite3"} {
+		t.Run(name, func(t *testing.T) {
+			pkgIdx := load(t, name)
+			require.NotNil(t, pkgIdx.shared)
+
+			pkgs, err := pkgIdx.Search(ctx, "client", WithMatchPartials())
+			require.NoError(t, err)
+			require.Len(t, pkgs, 1)
+			require.Equal(t, "example.com/shared/client", pkgs[0].ImportPath)
+			require.Equal(t, filepath.Join(codeRoots[1].Dir, "client"), pkgs[0].Dir)
+
+			// The rowids of local and shared packages overlap, so
+			// this must not match the shared package.
+			pkgs, err = pkgIdx.Search(ctx, "testdata")
+			require.NoError(t, err)
+			require.Equal(t, []string{"aslevy.com/go-doc/testdata"}, importPaths(pkgs))
+
+			pkgs, err = pkgIdx.Search(ctx, "nested", WithMatchPartials())
+			require.NoError(t, err)
+			require.Equal(t, []string{
+				"aslevy.com/go-doc/testdata/nested",
+				"aslevy.com/go-doc/testdata/nested/nested",
+				"aslevy.com/go-doc/testdata/nested/empty",
+			}, importPaths(pkgs))
+
+			syms, err := pkgIdx.Which(ctx, "NewClient")
+			require.NoError(t, err)
+			require.Len(t, syms, 1)
+			require.Equal(t, "example.com/shared/client", syms[0].ImportPath)
+
+			docs, err := pkgIdx.SearchDocs(ctx, "shared service")
+			require.NoError(t, err)
+			var names []string
+			for _, doc := range docs {
+				require.Equal(t, "example.com/shared/client", doc.ImportPath)
+				names = append(names, doc.Name)
+			}
+			require.ElementsMatch(t, []string{"client", "NewClient"}, names)
+
+			docs, err = pkgIdx.SearchDocs(ctx, "constructor")
+			require.NoError(t, err)
+			require.Len(t, docs, 1)
+			require.Equal(t, "aslevy.com/go-doc/testdata", docs[0].ImportPath)
+
+			var numShared int
+			require.NoError(t, pkgIdx.db.QueryRowContext(ctx,
+				`SELECT count(*) FROM main.package INNER JOIN main.module ON moduleId=module.rowid WHERE shared;`,
+			).Scan(&numShared))
+			require.Zero(t, numShared, "packages of shared modules are held in the local index")
+		})
+	}
+}
+
+func TestSharedIndex_InMemory(t *testing.T) {
+	ctx := context.Background()
+	sharedPath := filepath.Join(t.TempDir(), "shared.sqlite3")
+	codeRoots := append(testdataCodeRoots(), sharedTestModule(t))
+
+	pkgIdx, err := Load(ctx, InMemory, codeRoots, loadOpts(), WithSharedIndex(sharedPath))
+	require.NoError(t, err)
+	t.Cleanup(func() { require.NoError(t, pkgIdx.Close()) })
+	require.Nil(t, pkgIdx.shared, "an in-memory index holds all packages")
+
+	pkgs, err := pkgIdx.Search(ctx, "client", WithMatchPartials())
+	require.NoError(t, err)
+	require.Equal(t, []string{"example.com/shared/client"}, importPaths(pkgs))
+	require.NoFileExists(t, sharedPath)
+}
+
+func TestSharedIndex_writeLocked(t *testing.T) {
+	require := require.New(t)
+	ctx := context.Background()
+	tmp := t.TempDir()
+	sharedPath := filepath.Join(tmp, "shared.sqlite3")
+	codeRoots := append(testdataCodeRoots(), sharedTestModule(t))
+	pkgIdx, err := Load(ctx, filepath.Join(tmp, "local.sqlite3"), codeRoots, loadOpts(),
+		WithSharedIndex(sharedPath),
+	)
+	require.NoError(err)
+	defer func() { require.NoError(pkgIdx.Close()) }()
+	require.NoError(pkgIdx.waitSync())
+
+	// Another process is writing to the shared index.
+	sharedDB, err := sql.Open("sqlite", dataSourceName(sharedPath))
+	require.NoError(err)
+	defer sharedDB.Close()
+	sharedTx, err := sharedDB.BeginTx(ctx, nil)
+	require.NoError(err)
+	defer sharedTx.Rollback()
+	_, err = sharedTx.ExecContext(ctx, `DELETE FROM module;`)
+	require.NoError(err)
+
+	// The local index can still be written, since the shared index is
+	// attached read-only, which also keeps it from being written through
+	// the local index.
+	tx, err := pkgIdx.db.BeginTx(ctx, nil)
+	require.NoError(err)
+	defer tx.Rollback()
+	_, err = tx.ExecContext(ctx, `UPDATE metadata SET updatedAt=CURRENT_TIMESTAMP;`)
+	require.NoError(err)
+	_, err = tx.ExecContext(ctx, `DELETE FROM shared.module;`)
+	require.ErrorContains(err, "readonly")
+}
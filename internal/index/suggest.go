@@ -0,0 +1,57 @@
+package index
+
+import (
+	"context"
+
+	"aslevy.com/go-doc/internal/godoc"
+	"aslevy.com/go-doc/internal/suggest"
+)
+
+// Suggest returns the packages which path may be a typo of, closest first,
+// for when path matches no package.
+//
+// The trailing segments of each import path are compared against path, so
+// that htpp suggests net/http. Packages at the same distance are ranked like
+// Search.
+func (idx *Index) Suggest(ctx context.Context, path string) ([]suggest.Match[godoc.PackageDir], error) {
+	if err := idx.waitSync(); err != nil {
+		return nil, err
+	}
+
+	const query = `
+SELECT
+  packageImportPath,
+  packageDir
+FROM
+  modulePackage
+ORDER BY
+  class            ASC,
+  moduleImportPath ASC,
+  relativeNumParts ASC,
+  relativePath     ASC;
+`
+	rows, err := idx.db.QueryContext(ctx, query)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	maxDist := suggest.MaxDistance(path)
+	var matches []suggest.Match[godoc.PackageDir]
+	for rows.Next() {
+		var pkg godoc.PackageDir
+		if err := rows.Scan(&pkg.ImportPath, &pkg.Dir); err != nil {
+			return nil, err
+		}
+		dist := suggest.PathDistance(path, pkg.ImportPath)
+		if dist < 0 || dist > maxDist {
+			continue
+		}
+		matches = append(matches, suggest.Match[godoc.PackageDir]{Value: pkg, Distance: dist})
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	suggest.Sort(matches)
+	return matches, nil
+}
@@ -0,0 +1,38 @@
+package index
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestSuggest(t *testing.T) {
+	ctx := context.Background()
+	pkgIdx, err := Load(ctx, dbMem, testdataCodeRoots(), loadOpts())
+	require.NoError(t, err)
+	t.Cleanup(func() { require.NoError(t, pkgIdx.Close()) })
+
+	tests := []struct {
+		path     string
+		expected []string
+		dist     int
+	}{
+		{"nestd", []string{"aslevy.com/go-doc/testdata/nested", "aslevy.com/go-doc/testdata/nested/nested"}, 1},
+		{"nested/nestde", []string{"aslevy.com/go-doc/testdata/nested/nested"}, 1},
+		{"tsetdata", []string{"aslevy.com/go-doc/testdata"}, 1},
+		{"zzzzzz", nil, 0},
+	}
+	for _, test := range tests {
+		t.Run(test.path, func(t *testing.T) {
+			matches, err := pkgIdx.Suggest(ctx, test.path)
+			require.NoError(t, err)
+			var importPaths []string
+			for _, m := range matches {
+				require.Equal(t, test.dist, m.Distance)
+				importPaths = append(importPaths, m.Value.ImportPath)
+			}
+			require.Equal(t, test.expected, importPaths)
+		})
+	}
+}
@@ -5,25 +5,39 @@ import (
 	"database/sql"
 	"errors"
 	"fmt"
+	"go/build"
 	"log"
 	"os"
 	"path"
 	"path/filepath"
 	"strings"
-	"time"
+
+	_module "golang.org/x/mod/module"
 
 	"aslevy.com/go-doc/internal/godoc"
+	"aslevy.com/go-doc/internal/walk"
 )
 
 var dlogSync = dlog.Child("sync")
 
-func (idx *Index) needsSync(ctx context.Context) (bool, error) {
+func (idx *Index) needsSync(ctx context.Context, codeRoots []godoc.PackageDir) (bool, error) {
 	switch idx.options.mode {
 	case ModeOff, ModeSkipSync:
 		return false, nil
 	case ModeForceSync:
 		return true, nil
 	}
+	return idx.resyncDue(ctx, codeRoots)
+}
+
+// resyncDue reports whether the index is out of date and should be synced,
+// regardless of the mode.
+func (idx *Index) resyncDue(ctx context.Context, codeRoots []godoc.PackageDir) (bool, error) {
+	if idx.options.isShared {
+		// The shared index only holds immutable modules, so it only
+		// needs to sync the modules it does not yet have.
+		return idx.missingModules(ctx, codeRoots)
+	}
 	var err error
 	idx.metadata, err = idx.selectMetadata(ctx)
 	if ignoreErrNoRows(err) != nil {
@@ -37,7 +51,72 @@ func (idx *Index) needsSync(ctx context.Context) (bool, error) {
 
 	dlogSync.Printf("created at: %v", idx.CreatedAt.Local())
 	dlogSync.Printf("updated at: %v", idx.UpdatedAt.Local())
-	return time.Since(idx.UpdatedAt) > idx.options.resyncInterval, nil
+	// The requirements of the local modules determine the versions of all
+	// other modules.
+	if hashModuleFiles(codeRoots, idx.options.goWork).changed(idx.ModuleFiles) {
+		return true, nil
+	}
+	return idx.localModulesChanged(ctx)
+}
+
+// localModulesChanged reports whether any directory of the local modules, or
+// any of the .go files within them, has been modified, created or removed since
+// it was last synced.
+func (idx *Index) localModulesChanged(ctx context.Context) (bool, error) {
+	errChanged := errors.New("changed")
+	err := idx.selectLocalDirectories(ctx, func(dir string, modTime int64) error {
+		if latest, err := localDirModTime(dir); err != nil || latest != modTime {
+			dlogSync.Printf("directory %q has changed", dir)
+			return errChanged
+		}
+		return nil
+	})
+	if errors.Is(err, errChanged) {
+		return true, nil
+	}
+	return false, err
+}
+
+// localDirModTime returns the latest modification time, in unix nanoseconds, of
+// the directory of a local package and the .go files directly within it.
+// Editing a file in place does not modify its directory, so the files must be
+// checked too.
+func localDirModTime(dir string) (int64, error) {
+	info, err := os.Stat(dir)
+	if err != nil {
+		return 0, err
+	}
+	latest := info.ModTime().UnixNano()
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return 0, err
+	}
+	for _, entry := range entries {
+		if entry.IsDir() || !strings.HasSuffix(entry.Name(), ".go") {
+			continue
+		}
+		info, err := entry.Info()
+		if err != nil {
+			// The file was removed since the directory was read,
+			// which modified the directory.
+			continue
+		}
+		latest = max(latest, info.ModTime().UnixNano())
+	}
+	return latest, nil
+}
+func (idx *Index) missingModules(ctx context.Context, codeRoots []godoc.PackageDir) (bool, error) {
+	for _, root := range codeRoots {
+		mod := parseModule(root)
+		exists, err := idx.hasModule(ctx, mod.ImportPath, mod.Version)
+		if err != nil {
+			return false, err
+		}
+		if !exists {
+			return true, nil
+		}
+	}
+	return false, nil
 }
 func ignoreErrNoRows(err error) error {
 	if errors.Is(err, sql.ErrNoRows) {
@@ -46,42 +125,125 @@ func ignoreErrNoRows(err error) error {
 	return err
 }
 
-func (idx *Index) syncCodeRoots(ctx context.Context, codeRoots []godoc.PackageDir) (retErr error) {
-	needsSync, err := idx.needsSync(ctx)
+// syncCodeRoots syncs the codeRoots if needed, unless another process is
+// already syncing the index, or will sync it, in which case the last synced
+// index is used.
+func (idx *Index) syncCodeRoots(ctx context.Context, codeRoots []godoc.PackageDir) error {
+	needsSync, err := idx.syncDue()
+	if err != nil || !needsSync {
+		return err
+	}
+	if detached, err := idx.syncDetached(ctx); err != nil || detached {
+		return err
+	}
+
+	err = idx.lock.tryLock()
+	if errors.Is(err, errLocked) {
+		synced, syncedErr := idx.hasSynced(ctx)
+		if syncedErr != nil {
+			return syncedErr
+		}
+		if synced {
+			dlogSync.Printf("another process is syncing %q, using the last synced index", idx.dbPath)
+			return nil
+		}
+		// There is no previous sync to fall back on.
+		dlogSync.Printf("waiting for another process to sync %q", idx.dbPath)
+		err = idx.lock.lock()
+	}
 	if err != nil {
+		return fmt.Errorf("failed to lock index: %w", err)
+	}
+	defer idx.lock.unlock()
+
+	// Another process may have just finished syncing.
+	needsSync, err = idx.needsSync(ctx, codeRoots)
+	if err != nil || !needsSync {
 		return err
 	}
-	if !needsSync {
-		return nil
+	return idx.syncCodeRootsLocked(ctx, codeRoots)
+}
+
+// syncDetached starts a detached sync if the index has been synced before
+// and the auto mode is used WithDetachedSync, and reports whether it did.
+func (idx *Index) syncDetached(ctx context.Context) (bool, error) {
+	if idx.options.detachSync == nil || idx.options.mode != ModeAutoSync {
+		return false, nil
+	}
+	synced, err := idx.hasSynced(ctx)
+	if err != nil || !synced {
+		return false, err
+	}
+	if err := idx.options.detachSync(); err != nil {
+		dlogSync.Printf("syncing %q in this process: %v", idx.dbPath, err)
+		return false, nil
+	}
+	dlogSync.Printf("using the last synced index %q until the detached sync is done", idx.dbPath)
+	return true, nil
+}
+
+// hasSynced reports whether the index has ever been synced.
+func (idx *Index) hasSynced(ctx context.Context) (bool, error) {
+	_, err := idx.selectMetadata(ctx)
+	if errors.Is(err, sql.ErrNoRows) {
+		return false, nil
 	}
+	return err == nil, err
+}
 
+// syncCodeRootsLocked syncs the codeRoots. The caller must hold idx.lock.
+func (idx *Index) syncCodeRootsLocked(ctx context.Context, codeRoots []godoc.PackageDir) (retErr error) {
 	dlogSync.Println("syncing code roots...")
+	// The marker is only cleared once the sync is committed, so that the
+	// next load checks the integrity of the index if it never is.
+	if err := idx.lock.markSyncing(); err != nil {
+		return fmt.Errorf("failed to mark sync in progress: %w", err)
+	}
+	defer func() {
+		if retErr != nil {
+			return
+		}
+		if err := idx.lock.clearSyncing(); err != nil {
+			dlogSync.Printf("failed to clear sync marker: %v", err)
+		}
+	}()
+
+	// The module files are hashed before anything is synced, so that any
+	// changes made to them during the sync cause another.
+	idx.moduleFiles = hashModuleFiles(codeRoots, idx.options.goWork)
+
+	// The end of the sync is reported after it is committed.
+	idx.progress = newSyncProgress(idx.options, len(codeRoots))
+	defer func() {
+		idx.progress.finish(retErr)
+		idx.progress = nil
+	}()
+
 	commitIfNilErr, err := idx.beginTx(ctx)
 	if err != nil {
 		return err
 	}
 	defer commitIfNilErr(&retErr)
 
-	pb := newProgressBar(idx.options, len(codeRoots)+1, "syncing code roots")
-	defer pb.Finish()
-
 	var keep []int64
 	for _, codeRoot := range codeRoots {
+		idx.progress.startModule(parseModule(codeRoot))
 		modIDs, err := idx.syncCodeRoot(ctx, codeRoot)
 		if err != nil {
 			return err
 		}
 		keep = append(keep, modIDs...)
-		pb.Add(1)
+		idx.progress.endModule()
 	}
 
-	const vendor = false
-	if err := idx.pruneModules(ctx, keep); err != nil {
-		return err
+	// The shared index is used by many local indexes, so it does not know
+	// which of its modules are still in use.
+	if !idx.options.isShared {
+		if err := idx.pruneModules(ctx, keep); err != nil {
+			return err
+		}
 	}
-	pb.Add(1)
-
-	return idx.upsertMetadata(ctx)
+	return idx.upsertMetadata(ctx, idx.moduleFiles)
 }
 func (idx *Index) beginTx(ctx context.Context) (commitIfNilErr func(*error), _ error) {
 	tx, err := idx.db.BeginTx(ctx, nil)
@@ -99,8 +261,8 @@ func (idx *Index) beginTx(ctx context.Context) (commitIfNilErr func(*error), _ e
 }
 
 func (idx *Index) syncCodeRoot(ctx context.Context, root godoc.PackageDir) (modIDs []int64, _ error) {
-	class, vendor := parseClassVendor(root)
-	if vendor {
+	mod := parseModule(root)
+	if mod.Vendor {
 		// ImportPath is empty for vendor directories, so we use the
 		// Dir instead so as not to conflict with the stdlib, which
 		// uses the empty import path.
@@ -110,15 +272,43 @@ func (idx *Index) syncCodeRoot(ctx context.Context, root godoc.PackageDir) (modI
 		root.ImportPath = root.Dir
 		return idx.syncVendoredModules(ctx, root)
 	}
-	return idx.syncModule(ctx, root, class)
+	if idx.notRequired[root.Dir] {
+		mod.Class = classNotRequired
+	}
+	// The packages of immutable modules are synced by the shared index.
+	mod.Shared = idx.shared != nil && isImmutable(mod)
+	return idx.syncModule(ctx, mod)
+}
+
+// parseModule returns the module of the code root.
+func parseModule(root godoc.PackageDir) module {
+	mod := module{
+		ImportPath: root.ImportPath,
+		Dir:        root.Dir,
+	}
+	mod.Class, mod.Vendor = parseClassVendor(root)
+	switch {
+	case mod.Vendor:
+	case mod.Class == classStdlib:
+		mod.Version = goRootVersion(root.Dir)
+	case isGOPATH(root):
+		// GOPATH roots have neither an import path nor a version, so
+		// they are told apart by their directory.
+		mod.Version = root.Dir
+	default:
+		mod.Version, _ = parseVersion(root.Dir)
+	}
+	return mod
 }
 
 func parseClassVendor(root godoc.PackageDir) (class, bool) {
 	if isVendor(root.Dir) {
 		return classRequired, true
 	}
-	switch root.ImportPath {
-	case "", "cmd":
+	switch {
+	case isGOPATH(root):
+		return classLocal, false
+	case root.ImportPath == "", root.ImportPath == "cmd":
 		return classStdlib, false
 	}
 	if _, hasVersion := parseVersion(root.Dir); hasVersion {
@@ -132,121 +322,306 @@ func parseVersion(dir string) (string, bool) {
 }
 func isVendor(dir string) bool { return filepath.Base(dir) == "vendor" }
 
-func (idx *Index) syncModule(ctx context.Context, root godoc.PackageDir, class int) (modIDs []int64, _ error) {
-	const vendor = false
-	modID, needsSync, err := idx.upsertModule(ctx, root, class, vendor)
+// isGOPATH reports whether root is the src directory of a GOPATH entry, which
+// like GOROOT/src has the empty import path, in GOPATH mode.
+func isGOPATH(root godoc.PackageDir) bool {
+	return root.ImportPath == "" && filepath.Clean(root.Dir) != filepath.Join(build.Default.GOROOT, "src")
+}
+
+// goRootVersion returns the Go version from the VERSION file in the GOROOT
+// which contains dir, or the empty string if it cannot be found, as is the case
+// for development builds of Go.
+func goRootVersion(dir string) string {
+	// dir is either $GOROOT/src or $GOROOT/src/cmd.
+	for i := 0; i < 2; i++ {
+		dir = filepath.Dir(dir)
+		data, err := os.ReadFile(filepath.Join(dir, "VERSION"))
+		if err != nil {
+			continue
+		}
+		version, _, _ := strings.Cut(string(data), "\n")
+		return strings.TrimSpace(version)
+	}
+	return ""
+}
+
+// isImmutable reports whether the packages of the module can never change,
+// which is the case for a released version of the stdlib or a module@version
+// in the module cache. Only such modules are held in the shared index.
+func isImmutable(mod module) bool {
+	return !mod.Vendor && mod.Version != "" && mod.Class != classLocal
+}
+
+func (idx *Index) syncModule(ctx context.Context, mod module) (modIDs []int64, _ error) {
+	modID, needsSync, err := idx.upsertModule(ctx, mod)
 	if err != nil {
 		return nil, err
 	}
 	modIDs = append(modIDs, modID)
 
-	if !needsSync && idx.options.mode != ModeForceSync {
-		dlogSync.Printf("code root %q is already synced", root.ImportPath)
+	if mod.Shared {
+		return modIDs, nil
+	}
+	// Local modules are always walked, as their packages may change at any
+	// time, but only their changed directories are read.
+	if !needsSync && mod.Class != classLocal && idx.options.mode != ModeForceSync {
+		dlogSync.Printf("code root %q is already synced", mod.ImportPath)
 		return modIDs, nil
 	}
 
-	return modIDs, idx.syncModulePackages(ctx, modID, root)
+	mod.ID = modID
+	if err := idx.syncModulePackages(ctx, mod); err != nil {
+		return nil, err
+	}
+	return modIDs, idx.updateModuleSyncedAt(ctx, modID)
 }
 
-func (idx *Index) upsertModule(ctx context.Context, root godoc.PackageDir, class class, vendor bool) (modID int64, needsSync bool, _ error) {
-	mod, err := idx.selectModule(ctx, root.ImportPath)
+func (idx *Index) upsertModule(ctx context.Context, mod module) (modID int64, needsSync bool, _ error) {
+	existing, err := idx.selectModule(ctx, mod.ImportPath, mod.Version, mod.Vendor)
 	if ignoreErrNoRows(err) != nil {
 		return -1, false, err
 	}
-	if mod.Dir == root.Dir {
+	if existing.Dir == mod.Dir && existing.Shared == mod.Shared && existing.Replace == mod.Replace {
 		// The module is already in the database and the directory
 		// hasn't changed, so we assume we are synced.
-		return mod.ID, false, nil
+		if existing.Class != mod.Class {
+			// The module has become required, or no longer is,
+			// which does not change its packages.
+			mod.ID = existing.ID
+			if err := idx.updateModule(ctx, mod); err != nil {
+				return -1, false, err
+			}
+		}
+		return existing.ID, false, nil
 	}
 
-	if mod.ID < 1 {
-		mod.ID, err = idx.insertModule(ctx, root, class, vendor)
+	if existing.ID < 1 {
+		mod.ID, err = idx.insertModule(ctx, mod)
 		if err != nil {
 			return -1, false, err
 		}
 	} else {
-		if err := idx.updateModule(ctx, mod.ID, root, class, vendor); err != nil {
+		mod.ID = existing.ID
+		if err := idx.updateModule(ctx, mod); err != nil {
+			return -1, false, err
+		}
+		// The module has moved, likely to a new version, is now
+		// shared, or its replacement has changed, so its existing
+		// packages may be stale.
+		if err := idx.deleteModulePackages(ctx, mod.ID); err != nil {
+			return -1, false, err
+		}
+		if err := idx.deleteModuleDirectories(ctx, mod.ID); err != nil {
 			return -1, false, err
 		}
 	}
 	return mod.ID, true, nil
 }
 
-func (idx *Index) syncModulePackages(ctx context.Context, modID int64, root godoc.PackageDir) error {
+// syncModulePackages walks the module for packages.
+//
+// The modification time of each directory is recorded, so that directories
+// which have not changed since the last sync do not need to be read again.
+// Their subdirectories, and whether they hold a package, are known from the
+// index. Every directory must still be visited, as a change to a directory
+// does not change the modification time of its parent.
+//
+// Directories are read, and the symbols and imports of new packages are
+// loaded, concurrently, but all changes to the index are made from this
+// goroutine within the transaction.
+func (idx *Index) syncModulePackages(ctx context.Context, mod module) error {
+	modID := mod.ID
+	root := godoc.NewPackageDir(mod.ImportPath, filepath.Clean(mod.Dir)) // because filepath.Join will do it anyway
 	dlogSync.Printf("syncing module packages for %q in %q", root.ImportPath, root.Dir)
-	root.Dir = filepath.Clean(root.Dir) // because filepath.Join will do it anyway
 
-	// this is the queue of directories to examine in this pass.
-	this := []godoc.PackageDir{}
-	// next is the queue of directories to examine in the next pass.
-	next := []godoc.PackageDir{root}
+	known, err := idx.selectDirectories(ctx, modID)
+	if err != nil {
+		return fmt.Errorf("failed to select directories: %w", err)
+	}
+	// subDirs holds the known subdirectories of each known directory.
+	subDirs := make(map[string][]string, len(known))
+	for relativePath := range known {
+		if relativePath == "" {
+			continue
+		}
+		parent, name := path.Split(relativePath)
+		parent = strings.TrimSuffix(parent, "/")
+		subDirs[parent] = append(subDirs[parent], name)
+	}
+	pkgIDs, err := idx.selectPackageIDs(ctx, modID)
+	if err != nil {
+		return fmt.Errorf("failed to select packages: %w", err)
+	}
 
-	var keep []int64
-	for len(next) > 0 {
-		dlogSync.Printf("descending")
-		this, next = next, this[0:0]
-		for _, pkg := range this {
-			dlogSync.Printf("walking %q", pkg)
-			fd, err := os.Open(pkg.Dir)
-			if err != nil {
-				log.Print(err)
-				continue
+	// read is called concurrently, so it must not use the transaction.
+	read := func(ctx context.Context, pkg godoc.PackageDir) (dir walkedDir) {
+		dir.relativePath = packageRelativePath(root, pkg)
+		if mod.Class == classLocal {
+			dir.modTime, dir.err = localDirModTime(pkg.Dir)
+		} else {
+			var info os.FileInfo
+			info, dir.err = os.Stat(pkg.Dir)
+			if dir.err == nil {
+				dir.modTime = info.ModTime().UnixNano()
 			}
+		}
+		if dir.err != nil {
+			return
+		}
+		dir.known, dir.isKnown = known[dir.relativePath]
+		_, hasPackage := pkgIDs[dir.relativePath]
+		if dir.unchanged() && idx.options.mode != ModeForceSync {
+			dir.HasGoFiles = hasPackage
+			dir.SubDirs = subDirs[dir.relativePath]
+			return
+		}
 
-			entries, err := fd.Readdir(0)
-			fd.Close()
-			if err != nil {
-				log.Print(err)
-				continue
+		dir.changed = true
+		dir.Dir, dir.err = walk.ReadDir(pkg.Dir, true)
+		if dir.HasGoFiles && (!hasPackage || dir.isKnown) {
+			// The package will be inserted.
+			dir.symbols, dir.symbolsOK = idx.loadPackageSymbols(ctx, root, pkg)
+			dir.summary = loadPackageSummary(root, pkg)
+			if mod.Class == classLocal {
+				// The imports of local packages are used to
+				// rank search results.
+				dir.imports = loadPackageImports(pkg)
 			}
-			hasGoFiles := false
-			for _, entry := range entries {
-				name := entry.Name()
-				// For plain files, remember if this directory contains any .go
-				// source files, but ignore them otherwise.
-				if !entry.IsDir() {
-					if !hasGoFiles && strings.HasSuffix(name, ".go") {
-						hasGoFiles = true
-						pkgID, err := idx.syncPackage(ctx, modID, root, pkg)
-						if err != nil {
-							return err
-						}
-						if pkgID > 0 {
-							keep = append(keep, pkgID)
-						}
-					}
-					continue
-				}
-				// Entry is a directory.
+		}
+		return
+	}
 
-				// The go tool ignores directories starting with ., _, or named "testdata".
-				if name[0] == '.' || name[0] == '_' || name == "testdata" {
-					continue
+	var keep, keepDirs []int64
+	visit := func(pkg godoc.PackageDir, dir walkedDir) ([]godoc.PackageDir, error) {
+		if dir.err != nil {
+			log.Print(dir.err)
+			return nil, nil
+		}
+		if !dir.changed {
+			dlogSync.Printf("unchanged %q", pkg)
+			keepDirs = append(keepDirs, dir.known.ID)
+			if pkgID, ok := pkgIDs[dir.relativePath]; ok {
+				keep = append(keep, pkgID)
+			}
+		} else {
+			dlogSync.Printf("walked %q", pkg)
+			dirID, err := idx.upsertDirectory(ctx, modID, dir.relativePath, dir.modTime)
+			if err != nil {
+				return nil, err
+			}
+			keepDirs = append(keepDirs, dirID)
+			if dir.isKnown && (!dir.unchanged() || idx.options.mode == ModeForceSync) {
+				// Files were added, removed or edited, or a
+				// sync is forced, so any existing package must
+				// be synced again.
+				if err := idx.deleteStalePackage(ctx, modID, dir.relativePath); err != nil {
+					return nil, err
 				}
-				// Ignore vendor directories and stop at module boundaries.
-				if name == "vendor" {
-					continue
+			}
+			if dir.HasGoFiles {
+				pkgID, err := idx.syncPackageFunc(ctx, modID, root, pkg, func(pkgID int64) error {
+					if err := idx.syncPackageSummary(ctx, pkgID, pkg.ImportPath, dir.summary); err != nil {
+						return err
+					}
+					if err := idx.insertPackageImports(ctx, pkgID, dir.imports); err != nil {
+						return err
+					}
+					if !dir.symbolsOK {
+						return nil
+					}
+					return idx.insertPackageSymbols(ctx, pkgID, dir.symbols)
+				})
+				if err != nil {
+					return nil, err
 				}
-				if fi, err := os.Stat(filepath.Join(pkg.Dir, name, "go.mod")); err == nil && !fi.IsDir() {
-					continue
+				if pkgID > 0 {
+					keep = append(keep, pkgID)
 				}
-				// Remember this (fully qualified) directory for the next pass.
-				subPkg := godoc.NewPackageDir(
-					path.Join(pkg.ImportPath, name),
-					filepath.Join(pkg.Dir, name),
-				)
-				dlogSync.Printf("queuing %q", subPkg.ImportPath)
-				next = append(next, subPkg)
+				idx.progress.addPackage()
 			}
 		}
+
+		// Remember these (fully qualified) directories for the next pass.
+		next := make([]godoc.PackageDir, len(dir.SubDirs))
+		for i, name := range dir.SubDirs {
+			next[i] = godoc.NewPackageDir(
+				path.Join(pkg.ImportPath, name),
+				filepath.Join(pkg.Dir, name),
+			)
+			dlogSync.Printf("queuing %q", next[i].ImportPath)
+		}
+		return next, nil
+	}
+	if err := walk.BFS(ctx, walk.DefaultWorkers, root, read, visit); err != nil {
+		return err
 	}
 
+	if err := idx.pruneDirectories(ctx, modID, keepDirs); err != nil {
+		return err
+	}
 	return idx.prunePackages(ctx, modID, keep)
 }
 
+// walkedDir is the result of reading a directory in syncModulePackages.
+type walkedDir struct {
+	walk.Dir
+	err error
+
+	relativePath string
+	modTime      int64
+
+	// known is the directory as it was last synced, if isKnown.
+	known   directory
+	isKnown bool
+
+	// changed is true if the directory was read.
+	changed bool
+
+	// symbols are the symbols of the package in the directory, if it
+	// will be inserted.
+	symbols   godoc.PackageSymbols
+	symbolsOK bool
+	// summary summarizes the package in the directory, if it will be
+	// inserted.
+	summary godoc.PackageSummary
+	// imports are the import paths imported by the package in the
+	// directory, if it will be inserted and it is in a local module.
+	imports []string
+}
+
+func (dir walkedDir) unchanged() bool { return dir.isKnown && dir.known.ModTime == dir.modTime }
+
+func packageRelativePath(root, pkg godoc.PackageDir) string {
+	return strings.TrimPrefix(pkg.ImportPath[len(root.ImportPath):], "/")
+}
+func (idx *Index) deleteStalePackage(ctx context.Context, modID int64, relativePath string) error {
+	pkgID, err := idx.selectPackageID(ctx, modID, relativePath)
+	if err != nil {
+		return ignoreErrNoRows(err)
+	}
+	return idx.deletePackage(ctx, pkgID)
+}
+
 func (idx *Index) syncPackage(ctx context.Context, modID int64, root, pkg godoc.PackageDir) (int64, error) {
+	return idx.syncPackageFunc(ctx, modID, root, pkg, func(pkgID int64) error {
+		pkgSyms, ok := idx.loadPackageSymbols(ctx, root, pkg)
+		summary := loadPackageSummary(root, pkg)
+		if err := idx.syncPackageSummary(ctx, pkgID, pkg.ImportPath, summary); err != nil {
+			return err
+		}
+		if !ok {
+			return nil
+		}
+		return idx.insertPackageSymbols(ctx, pkgID, pkgSyms)
+	})
+}
+
+// syncPackageFunc is like syncPackage, but inserted is called to insert
+// anything else about the package, like its symbols, only if the package is
+// inserted.
+func (idx *Index) syncPackageFunc(ctx context.Context, modID int64, root, pkg godoc.PackageDir, inserted func(pkgID int64) error) (int64, error) {
 	dlogSync.Printf("syncing package %q in %q", pkg.ImportPath, pkg.Dir)
-	relativePath := strings.TrimPrefix(pkg.ImportPath[len(root.ImportPath):], "/")
+	relativePath := packageRelativePath(root, pkg)
 	pkgID, err := idx.selectPackageID(ctx, modID, relativePath)
 	if ignoreErrNoRows(err) != nil {
 		return -1, err
@@ -260,7 +635,10 @@ func (idx *Index) syncPackage(ctx context.Context, modID int64, root, pkg godoc.
 		return -1, err
 	}
 
-	return pkgID, idx.syncPartials(ctx, pkgID, pkg.ImportPath)
+	if err := idx.syncPartials(ctx, pkgID, pkg.ImportPath); err != nil {
+		return -1, err
+	}
+	return pkgID, inserted(pkgID)
 }
 
 func (idx *Index) syncPartials(ctx context.Context, pkgID int64, importPath string) error {
@@ -274,3 +652,132 @@ func (idx *Index) syncPartials(ctx context.Context, pkgID int64, importPath stri
 	}
 	return nil
 }
+
+// syncPackageSummary records the summary of the package, and inserts the
+// partials of its name. Nothing is recorded if the package failed to load.
+func (idx *Index) syncPackageSummary(ctx context.Context, pkgID int64, importPath string, summary godoc.PackageSummary) error {
+	if summary.Name == "" {
+		return nil
+	}
+	if err := idx.updatePackageSummary(ctx, pkgID, summary); err != nil {
+		return err
+	}
+	for _, parts := range namePartials(importPath, summary.Name) {
+		if _, err := idx.insertPartial(ctx, pkgID, parts); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// namePartials returns the partials of importPath with its last element, after
+// dropping any major version suffix, replaced by name. So gopkg.in/yaml.v3 may
+// be found by yaml, and github.com/jackc/pgx/v5 by pgx or jackc/pgx.
+//
+// Commands are only found by their path, as their name is always main.
+func namePartials(importPath, name string) []string {
+	if name == "main" {
+		return nil
+	}
+	prefix, pathMajor, _ := _module.SplitPathVersion(importPath)
+	if pathMajor == "" {
+		prefix = importPath
+	}
+	parts := strings.Split(prefix, "/")
+	if pathMajor == "" && parts[len(parts)-1] == name {
+		// The partials of the import path already include name.
+		return nil
+	}
+	parts[len(parts)-1] = name
+
+	partials := make([]string, len(parts))
+	for i := range parts {
+		partials[i] = strings.Join(parts[len(parts)-1-i:], "/")
+	}
+	return partials
+}
+
+// loadPackageSummary reads the package clauses and doc comments of the
+// package. It is safe to call concurrently.
+func loadPackageSummary(root, pkg godoc.PackageDir) godoc.PackageSummary {
+	buildPkg, err := build.ImportDir(packageDir(root, pkg), build.ImportComment)
+	if err != nil {
+		dlogSync.Printf("failed to load summary of package %q: %v", pkg.ImportPath, err)
+		return godoc.PackageSummary{}
+	}
+	return godoc.PackageSummary{
+		Name:      buildPkg.Name,
+		Synopsis:  godoc.Synopsis(buildPkg.Name, buildPkg.Doc),
+		IsCommand: buildPkg.IsCommand(),
+		NumFiles:  len(buildPkg.GoFiles) + len(buildPkg.CgoFiles),
+	}
+}
+
+// packageDir returns the directory of the package, which is not set for
+// vendored packages.
+func packageDir(root, pkg godoc.PackageDir) string {
+	if pkg.Dir != "" {
+		return pkg.Dir
+	}
+	return filepath.Join(root.Dir, filepath.FromSlash(packageRelativePath(root, pkg)))
+}
+
+// loadPackageSymbols loads the symbols of the package, if the Index was
+// loaded WithSymbolLoader. It is safe to call concurrently.
+func (idx *Index) loadPackageSymbols(ctx context.Context, root, pkg godoc.PackageDir) (godoc.PackageSymbols, bool) {
+	if idx.options.loadSymbols == nil {
+		return godoc.PackageSymbols{}, false
+	}
+	pkg.Dir = packageDir(root, pkg)
+	dlogSync.Printf("loading symbols for package %q", pkg.ImportPath)
+	pkgSyms, err := idx.options.loadSymbols(ctx, pkg)
+	if err != nil {
+		// A package which fails to load should not prevent the rest
+		// of the index from syncing.
+		dlogSync.Printf("failed to load symbols for package %q: %v", pkg.ImportPath, err)
+		return godoc.PackageSymbols{}, false
+	}
+	return pkgSyms, true
+}
+
+func (idx *Index) insertPackageSymbols(ctx context.Context, pkgID int64, pkgSyms godoc.PackageSymbols) error {
+	if err := idx.insertDocs(ctx, -pkgID, pkgID, pkgSyms.Name, pkgSyms.Doc); err != nil {
+		return err
+	}
+	for _, sym := range pkgSyms.Symbols {
+		symID, err := idx.insertSymbol(ctx, pkgID, sym)
+		if err != nil {
+			return err
+		}
+		if symID < 1 {
+			continue
+		}
+		if err := idx.insertDocs(ctx, symID, pkgID, symbolName(sym), sym.Doc); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// loadPackageImports returns the import paths imported by the package,
+// including by its tests. It is safe to call concurrently.
+func loadPackageImports(pkg godoc.PackageDir) []string {
+	buildPkg, err := build.ImportDir(pkg.Dir, 0)
+	if err != nil {
+		dlogSync.Printf("failed to load imports for package %q: %v", pkg.ImportPath, err)
+		return nil
+	}
+	imports := make([]string, 0, len(buildPkg.Imports)+len(buildPkg.TestImports)+len(buildPkg.XTestImports))
+	imports = append(imports, buildPkg.Imports...)
+	imports = append(imports, buildPkg.TestImports...)
+	imports = append(imports, buildPkg.XTestImports...)
+	return imports
+}
+
+// symbolName returns <sym> or <type>.<method|field>.
+func symbolName(sym godoc.Symbol) string {
+	if sym.Type == "" {
+		return sym.Name
+	}
+	return sym.Type + "." + sym.Name
+}
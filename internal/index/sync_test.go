@@ -2,10 +2,13 @@ package index
 
 import (
 	"context"
+	"os"
 	"path/filepath"
 	"testing"
+	"time"
 
 	"aslevy.com/go-doc/internal/benchmark"
+	"aslevy.com/go-doc/internal/godoc"
 	"github.com/stretchr/testify/require"
 )
 
@@ -21,7 +24,7 @@ func dbFilePath(tb testing.TB) string {
 	return path
 }
 
-func loadOpts() Option { return WithOptions(WithNoProgressBar(), WithResyncInterval(0)) }
+func loadOpts() Option { return WithNoProgressBar() }
 
 // BenchmarkLoadSync_stdlib benchmarks the time it takes to sync an index of
 // the stdlib from scratch and write it to the filesystem.
@@ -143,3 +146,159 @@ func BenchmarkLoadSkipSync_stdlib(b *testing.B) {
 	})
 	b.Logf("index sync %+v", idx.metadata)
 }
+
+func TestSyncLocalChanges(t *testing.T) {
+	require := require.New(t)
+	ctx := context.Background()
+	dbPath := dbFilePath(t)
+	root := t.TempDir()
+	codeRoots := []godoc.PackageDir{godoc.NewPackageDir("example.com/local", root)}
+	opts := loadOpts()
+
+	writePkg := func(dir string) {
+		dir = filepath.Join(root, dir)
+		require.NoError(os.MkdirAll(dir, 0755))
+		require.NoError(os.WriteFile(filepath.Join(dir, "pkg.go"), []byte("package pkg\n"), 0644))
+	}
+	search := func() []string {
+		pkgIdx, err := Load(ctx, dbPath, codeRoots, opts)
+		require.NoError(err)
+		defer func() { require.NoError(pkgIdx.Close()) }()
+		pkgs, err := pkgIdx.Search(ctx, "example.com/local", WithMatchPartials())
+		require.NoError(err)
+		return importPaths(pkgs)
+	}
+
+	writePkg("a")
+	writePkg("b/c")
+	require.Equal([]string{"example.com/local/a", "example.com/local/b/c"}, search())
+
+	writePkg("b/c/d")
+	require.Equal([]string{"example.com/local/a", "example.com/local/b/c", "example.com/local/b/c/d"}, search())
+
+	require.NoError(os.RemoveAll(filepath.Join(root, "a")))
+	require.Equal([]string{"example.com/local/b/c", "example.com/local/b/c/d"}, search())
+}
+
+func TestSyncLocalChanges_editedFile(t *testing.T) {
+	require := require.New(t)
+	ctx := context.Background()
+	dbPath := dbFilePath(t)
+	root := t.TempDir()
+	codeRoots := []godoc.PackageDir{godoc.NewPackageDir("example.com/local", root)}
+	opts := WithOptions(loadOpts(), WithSymbolLoader(testSymbols))
+	file := filepath.Join(root, "pkg.go")
+
+	which := func(name string) []SymbolMatch {
+		pkgIdx, err := Load(ctx, dbPath, codeRoots, opts)
+		require.NoError(err)
+		defer func() { require.NoError(pkgIdx.Close()) }()
+		matches, err := pkgIdx.Which(ctx, name)
+		require.NoError(err)
+		return matches
+	}
+
+	require.NoError(os.WriteFile(file, []byte("package pkg\n\nfunc Old() {}\n"), 0644))
+	require.Len(which("Old"), 1)
+
+	// Editing a file in place does not modify its directory.
+	info, err := os.Stat(root)
+	require.NoError(err)
+	require.NoError(os.WriteFile(file, []byte("package pkg\n\nfunc New() {}\n"), 0644))
+	later := info.ModTime().Add(time.Second)
+	require.NoError(os.Chtimes(file, later, later))
+	require.NoError(os.Chtimes(root, info.ModTime(), info.ModTime()))
+	require.Len(which("New"), 1)
+	require.Empty(which("Old"))
+
+	// A forced sync loads every package again, even if nothing looks
+	// modified.
+	require.NoError(os.WriteFile(file, []byte("package pkg\n\nfunc Forced() {}\n"), 0644))
+	require.NoError(os.Chtimes(file, later, later))
+	require.NoError(os.Chtimes(root, info.ModTime(), info.ModTime()))
+	require.Empty(which("Forced"))
+	opts = WithOptions(opts, WithForceSync())
+	require.Len(which("Forced"), 1)
+}
+
+func TestSyncGOPATH(t *testing.T) {
+	require := require.New(t)
+	ctx := context.Background()
+	// Each GOPATH entry is a code root with the empty import path.
+	var codeRoots []godoc.PackageDir
+	for _, importPath := range []string{"example.com/a", "example.com/b"} {
+		src := filepath.Join(t.TempDir(), "src")
+		dir := filepath.Join(src, filepath.FromSlash(importPath))
+		require.NoError(os.MkdirAll(dir, 0755))
+		require.NoError(os.WriteFile(filepath.Join(dir, "pkg.go"), []byte("package pkg\n"), 0644))
+		codeRoots = append(codeRoots, godoc.NewPackageDir("", src))
+	}
+
+	pkgIdx, err := Load(ctx, dbFilePath(t), codeRoots, loadOpts())
+	require.NoError(err)
+	defer func() { require.NoError(pkgIdx.Close()) }()
+	pkgs, err := pkgIdx.Search(ctx, "example.com", WithMatchPartials())
+	require.NoError(err)
+	require.Equal([]string{"example.com/a", "example.com/b"}, importPaths(pkgs))
+
+	stats, err := pkgIdx.Stats(ctx)
+	require.NoError(err)
+	require.Len(stats.Modules, 2)
+	for i, mod := range stats.Modules {
+		require.Equal("local", mod.Class)
+		require.Equal(codeRoots[i].Dir, mod.Dir)
+		require.False(mod.Shared)
+	}
+	require.False(stats.ResyncDue)
+
+	// GOPATH roots are not modules.
+	_, ok := pkgIdx.Module(pkgs[0].Dir)
+	require.False(ok)
+}
+
+func TestSyncVendored(t *testing.T) {
+	require := require.New(t)
+	ctx := context.Background()
+	vendor := filepath.Join(t.TempDir(), "vendor")
+	for _, importPath := range []string{"example.com/a", "example.com/b/c"} {
+		dir := filepath.Join(vendor, filepath.FromSlash(importPath))
+		require.NoError(os.MkdirAll(dir, 0755))
+		require.NoError(os.WriteFile(filepath.Join(dir, "pkg.go"), []byte("package pkg\n"), 0644))
+	}
+	const modulesTxt = `# example.com/a v1.2.3
+## explicit; go 1.19
+example.com/a
+# example.com/b v0.1.0 => ../b
+## explicit
+example.com/b/c
+# example.com/b => ../b
+`
+	require.NoError(os.WriteFile(filepath.Join(vendor, "modules.txt"), []byte(modulesTxt), 0644))
+
+	pkgIdx, err := Load(ctx, dbFilePath(t), []godoc.PackageDir{godoc.NewPackageDir("", vendor)}, loadOpts())
+	require.NoError(err)
+	defer func() { require.NoError(pkgIdx.Close()) }()
+	pkgs, err := pkgIdx.Search(ctx, "example.com", WithMatchPartials())
+	require.NoError(err)
+	require.Equal([]string{"example.com/a", "example.com/b/c"}, importPaths(pkgs))
+
+	version, ok := pkgIdx.ModuleVersion(pkgs[0].Dir)
+	require.True(ok)
+	require.Equal("v1.2.3", version)
+	version, ok = pkgIdx.ModuleVersion(pkgs[1].Dir)
+	require.True(ok)
+	require.Equal("v0.1.0 => ../b", version)
+	_, ok = pkgIdx.ModuleVersion(vendor)
+	require.False(ok)
+
+	mod, ok := pkgIdx.Module(pkgs[1].Dir)
+	require.True(ok)
+	require.Equal(godoc.Module{
+		Path:    "example.com/b",
+		Version: "v0.1.0",
+		Replace: "../b",
+		Class:   "vendored",
+	}, mod)
+	_, ok = pkgIdx.Module(vendor)
+	require.False(ok)
+}
@@ -2,16 +2,19 @@ package index
 
 import (
 	"context"
-	"log"
-	"os"
+	"path/filepath"
 
 	"aslevy.com/go-doc/internal/godoc"
 	"aslevy.com/go-doc/internal/vendored"
 )
 
 func (idx *Index) syncVendoredModules(ctx context.Context, vendorRoot godoc.PackageDir) ([]int64, error) {
-	const vendor = true
-	modID, needsSync, err := idx.upsertModule(ctx, vendorRoot, classLocal, vendor)
+	modID, needsSync, err := idx.upsertModule(ctx, module{
+		ImportPath: vendorRoot.ImportPath,
+		Dir:        vendorRoot.Dir,
+		Class:      classLocal,
+		Vendor:     true,
+	})
 	if err != nil {
 		return nil, err
 	}
@@ -21,13 +24,25 @@ func (idx *Index) syncVendoredModules(ctx context.Context, vendorRoot godoc.Pack
 	}
 
 	modIDs := []int64{modID}
-	if err := vendored.Parse(ctx, vendorRoot.Dir, func(ctx context.Context, mod godoc.PackageDir, pkgs ...godoc.PackageDir) error {
-		pkgKeep := make([]int64, len(pkgs))
-		modID, _, err := idx.upsertModule(ctx, mod, classRequired, vendor)
+	if err := vendored.Parse(ctx, vendorRoot.Dir, func(ctx context.Context, vendoredMod vendored.Module, pkgs ...godoc.PackageDir) error {
+		if len(pkgs) == 0 {
+			// The module is only listed to record its replacement.
+			return nil
+		}
+		pkgKeep := make([]int64, 0, len(pkgs))
+		modID, _, err := idx.upsertModule(ctx, module{
+			ImportPath: vendoredMod.Path,
+			Version:    vendoredMod.Version,
+			Replace:    vendoredMod.Replace(),
+			Dir:        vendoredMod.Dir,
+			Class:      classRequired,
+			Vendor:     true,
+		})
 		if err != nil {
 			return err
 		}
 		modIDs = append(modIDs, modID)
+		mod := vendoredMod.PackageDir()
 		for _, pkg := range pkgs {
 			pkgID, err := idx.syncPackage(ctx, modID, mod, pkg)
 			if err != nil {
@@ -35,20 +50,22 @@ func (idx *Index) syncVendoredModules(ctx context.Context, vendorRoot godoc.Pack
 			}
 			pkgKeep = append(pkgKeep, pkgID)
 		}
-		return idx.prunePackages(ctx, modID, pkgKeep)
+		if err := idx.prunePackages(ctx, modID, pkgKeep); err != nil {
+			return err
+		}
+		return idx.updateModuleSyncedAt(ctx, modID)
 	}); err != nil {
 		return nil, err
 	}
 	return modIDs, nil
 }
 
+// vendorUnchanged reports whether the modules.txt file of the vendor
+// directory is unchanged since the last sync.
 func (idx *Index) vendorUnchanged(vendor godoc.PackageDir) bool {
-	info, err := os.Stat(vendor.Dir)
-	if err != nil {
-		log.Printf("failed to stat %s: %v", vendor.Dir, err)
-		return true
-	}
-	return idx.UpdatedAt.After(info.ModTime())
+	modulesTxt := filepath.Join(vendor.Dir, "modules.txt")
+	hash, ok := idx.moduleFiles[modulesTxt]
+	return ok && idx.ModuleFiles[modulesTxt] == hash
 }
 
 func (idx *Index) vendoredModuleIDs(ctx context.Context) ([]int64, error) {
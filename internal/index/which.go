@@ -0,0 +1,90 @@
+package index
+
+import (
+	"context"
+	"database/sql"
+	"strings"
+
+	"aslevy.com/go-doc/internal/godoc"
+)
+
+// SymbolMatch is an exported symbol along with the package which declares it.
+type SymbolMatch struct {
+	godoc.PackageDir
+	godoc.Symbol
+}
+
+// Which returns all indexed symbols with the given name, ranked by their
+// package in the same order as Search.
+//
+// The name may be of the form <type>.<method|field> to match methods and
+// fields, otherwise only package level symbols are matched. Names are matched
+// case insensitively, so callers should apply any stricter matching rules
+// themselves.
+//
+// Symbols are only indexed if the Index was loaded WithSymbolLoader.
+func (idx *Index) Which(ctx context.Context, name string) ([]SymbolMatch, error) {
+	if err := idx.waitSync(); err != nil {
+		return nil, err
+	}
+
+	typ, name, _ := strings.Cut(name, ".")
+	if name == "" {
+		typ, name = "", typ
+	}
+
+	const query = `
+SELECT
+  packageImportPath,
+  packageDir,
+  kind,
+  type,
+  name,
+  summary
+FROM
+  packageSymbol
+WHERE
+  name = ? COLLATE NOCASE AND
+  type = ? COLLATE NOCASE
+ORDER BY
+  class            ASC,
+  moduleImportPath ASC,
+  relativeNumParts ASC,
+  relativePath     ASC,
+  type             ASC,
+  name             ASC;
+`
+	rows, err := idx.db.QueryContext(ctx, query, name, typ)
+	if err != nil {
+		return nil, err
+	}
+	var matches []SymbolMatch
+	return matches, scanSymbolMatches(rows, func(match SymbolMatch) error {
+		matches = append(matches, match)
+		return nil
+	})
+}
+func scanSymbolMatches(rows *sql.Rows, handler func(SymbolMatch) error) error {
+	defer rows.Close()
+	for rows.Next() {
+		match, err := scanSymbolMatch(rows)
+		if err != nil {
+			return err
+		}
+		if err := handler(match); err != nil {
+			return err
+		}
+	}
+	return rows.Err()
+}
+func scanSymbolMatch(row sqlRow) (SymbolMatch, error) {
+	var match SymbolMatch
+	return match, row.Scan(
+		&match.ImportPath,
+		&match.Dir,
+		&match.Kind,
+		&match.Type,
+		&match.Name,
+		&match.Summary,
+	)
+}
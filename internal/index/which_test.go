@@ -0,0 +1,106 @@
+package index
+
+import (
+	"context"
+	"go/build"
+	"go/doc"
+	"go/parser"
+	"go/token"
+	"io/fs"
+	"path/filepath"
+	"testing"
+
+	"aslevy.com/go-doc/internal/godoc"
+	"github.com/stretchr/testify/require"
+)
+
+func testdataCodeRoots() []godoc.PackageDir {
+	return []godoc.PackageDir{
+		godoc.NewPackageDir("aslevy.com/go-doc/testdata", filepath.FromSlash("../../testdata")),
+	}
+}
+
+// testSymbols is a simplified SymbolLoader which summarizes each symbol as its
+// kind and name.
+func testSymbols(_ context.Context, pkgDir godoc.PackageDir) (godoc.PackageSymbols, error) {
+	buildPkg, err := build.ImportDir(pkgDir.Dir, 0)
+	if err != nil {
+		return godoc.PackageSymbols{}, err
+	}
+	include := func(info fs.FileInfo) bool {
+		for _, name := range buildPkg.GoFiles {
+			if name == info.Name() {
+				return true
+			}
+		}
+		return false
+	}
+	pkgs, err := parser.ParseDir(token.NewFileSet(), pkgDir.Dir, include, parser.ParseComments)
+	if err != nil {
+		return godoc.PackageSymbols{}, err
+	}
+	docPkg := doc.New(pkgs[buildPkg.Name], pkgDir.ImportPath, 0)
+
+	pkgSyms := godoc.PackageSymbols{Name: docPkg.Name, Doc: docPkg.Doc}
+	add := func(kind godoc.SymbolKind, typ, name, doc string) {
+		pkgSyms.Symbols = append(pkgSyms.Symbols, godoc.Symbol{Kind: kind, Type: typ, Name: name, Summary: kind + " " + name, Doc: doc})
+	}
+	for _, fun := range docPkg.Funcs {
+		add(godoc.SymbolFunc, "", fun.Name, fun.Doc)
+	}
+	for _, typ := range docPkg.Types {
+		add(godoc.SymbolType, "", typ.Name, typ.Doc)
+		for _, fun := range typ.Funcs {
+			add(godoc.SymbolFunc, "", fun.Name, fun.Doc)
+		}
+		for _, method := range typ.Methods {
+			add(godoc.SymbolMethod, typ.Name, method.Name, method.Doc)
+		}
+	}
+	return pkgSyms, nil
+}
+
+func TestWhich(t *testing.T) {
+	ctx := context.Background()
+	pkgIdx, err := Load(ctx, dbMem, testdataCodeRoots(), loadOpts(), WithSymbolLoader(testSymbols))
+	require.NoError(t, err)
+	t.Cleanup(func() { require.NoError(t, pkgIdx.Close()) })
+
+	tests := []struct {
+		name    string
+		results []string
+	}{{
+		name:    "ExportedFunc",
+		results: []string{"aslevy.com/go-doc/testdata:func ExportedFunc"},
+	}, {
+		name:    "exportedfunc",
+		results: []string{"aslevy.com/go-doc/testdata:func ExportedFunc"},
+	}, {
+		name:    "Foo",
+		results: []string{"aslevy.com/go-doc/testdata/nested/nested:type Foo"},
+	}, {
+		name: "A",
+		results: []string{
+			"aslevy.com/go-doc/testdata/merge:func A",
+		},
+	}, {
+		name:    "ExportedType.ExportedMethod",
+		results: []string{"aslevy.com/go-doc/testdata:method ExportedMethod"},
+	}, {
+		// Methods are only matched with their type.
+		name: "ExportedMethod",
+	}, {
+		name: "NotASymbol",
+	}}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			matches, err := pkgIdx.Which(ctx, test.name)
+			require.NoError(t, err)
+			var results []string
+			for _, match := range matches {
+				results = append(results, match.ImportPath+":"+match.Summary)
+			}
+			require.Equal(t, test.results, results)
+		})
+	}
+}
@@ -0,0 +1,18 @@
+package index
+
+import (
+	"fmt"
+	"os"
+)
+
+// WritableDir creates dir if it does not exist and returns an error if files
+// cannot be created in it.
+func WritableDir(dir string) error {
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return fmt.Errorf("failed to create index dir: %w", err)
+	}
+	if err := canCreateFiles(dir); err != nil {
+		return fmt.Errorf("index dir is not writable: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,16 @@
+//go:build !unix
+
+package index
+
+import "os"
+
+// canCreateFiles returns an error if files cannot be created in dir, which
+// is checked by creating and removing a file where access(2) is not available.
+func canCreateFiles(dir string) error {
+	f, err := os.CreateTemp(dir, ".writable-*")
+	if err != nil {
+		return err
+	}
+	f.Close()
+	return os.Remove(f.Name())
+}
@@ -0,0 +1,9 @@
+//go:build unix
+
+package index
+
+import "golang.org/x/sys/unix"
+
+// canCreateFiles returns an error if files cannot be created in dir, without
+// creating one.
+func canCreateFiles(dir string) error { return unix.Access(dir, unix.W_OK|unix.X_OK) }
@@ -0,0 +1,152 @@
+// Package refs finds the references to a package and its symbols in the
+// source of other packages, so that the call sites of an API can be listed
+// alongside its docs.
+package refs
+
+import (
+	"context"
+	"flag"
+	"go/ast"
+	"go/parser"
+	"go/token"
+	"sort"
+
+	"aslevy.com/go-doc/internal/astutil"
+	_dlog "aslevy.com/go-doc/internal/dlog"
+	"aslevy.com/go-doc/internal/godoc"
+	"aslevy.com/go-doc/internal/walk"
+)
+
+var dlog = _dlog.Child("refs")
+
+// Requested is true if go doc should list the references to the package or
+// symbol given as arguments, instead of printing its docs.
+var Requested bool
+
+func AddFlags(fs *flag.FlagSet) {
+	fs.BoolVar(&Requested, "refs", false, "list every reference to <pkg>[.<sym>] in the local module or workspace")
+}
+
+// Ref is a reference to a package made by a selector expression, like
+// pkg.Symbol or pkg.Type.Method.
+type Ref struct {
+	// ImportPath of the package which makes the reference.
+	ImportPath string
+	token.Position
+
+	// Symbol is the name selected from the package, and Method is the
+	// name selected from Symbol, if any.
+	Symbol, Method string
+}
+
+// Find returns the references to the package with importPath made by the
+// packages, including their tests, within the module roots. See walk.Module.
+//
+// References are ordered by package and then by position. They are found
+// without type checking, so calling a method on a value of a type from the
+// package does not reference the method.
+func Find(ctx context.Context, roots []godoc.PackageDir, importPath string) ([]Ref, error) {
+	var refs []Ref
+	for _, root := range roots {
+		err := walk.Module(ctx, root, func(pkg godoc.PackageDir) error {
+			refs = append(refs, findInDir(pkg.Dir, pkg.ImportPath, importPath)...)
+			return nil
+		})
+		if err != nil {
+			return nil, err
+		}
+	}
+	return refs, nil
+}
+
+// findInDir returns the references to importPath in the files of dir, whose
+// package has dirImportPath.
+func findInDir(dir, dirImportPath, importPath string) []Ref {
+	fset := token.NewFileSet()
+	// Any files which did parse are still searched.
+	pkgs, err := parser.ParseDir(fset, dir, nil, 0)
+	if err != nil {
+		dlog.Printf("failed to parse %s: %v", dir, err)
+	}
+
+	var refs []Ref
+	for _, pkg := range pkgs {
+		resolver := astutil.NewPackageResolver(fset, pkg)
+		for _, file := range pkg.Files {
+			for _, sel := range findSelections(file) {
+				imp, err := resolver.Resolve(sel.pkgName, sel.pos)
+				if err != nil || imp.ImportPath != importPath {
+					// The name is not a package, e.g. it is a
+					// variable declared in another file.
+					continue
+				}
+				refs = append(refs, Ref{
+					ImportPath: dirImportPath,
+					Position:   fset.Position(sel.pos),
+					Symbol:     sel.symbol,
+					Method:     sel.method,
+				})
+			}
+		}
+	}
+	sort.Slice(refs, func(i, j int) bool {
+		a, b := refs[i].Position, refs[j].Position
+		if a.Filename != b.Filename {
+			return a.Filename < b.Filename
+		}
+		return a.Offset < b.Offset
+	})
+	return refs
+}
+
+// selection is a selector expression which may reference a package.
+type selection struct {
+	pkgName        string
+	pos            token.Pos
+	symbol, method string
+}
+
+// findSelections returns every selector expression in file whose X is an
+// identifier which is not declared in file, and so may be a package name. The
+// package names and their positions are collected in PackageReferences, like
+// the imports of rendered docs, so they can be resolved by
+// a PackageResolver.
+func findSelections(file *ast.File) []selection {
+	pkgRefs := make(astutil.PackageReferences)
+	symbols := make(map[token.Pos]string)
+	methods := make(map[token.Pos]string)
+	ast.Inspect(file, func(node ast.Node) bool {
+		sel, ok := node.(*ast.SelectorExpr)
+		if !ok {
+			return true
+		}
+		if x, ok := sel.X.(*ast.SelectorExpr); ok {
+			// This may be a method expression like pkg.Type.Method,
+			// so record the method and descend into pkg.Type.
+			if pkg, ok := x.X.(*ast.Ident); ok {
+				methods[pkg.Pos()] = sel.Sel.Name
+			}
+			return true
+		}
+		pkg, ok := sel.X.(*ast.Ident)
+		if !ok || pkg.Obj != nil {
+			return true
+		}
+		pkgRefs.Add(pkg.Name, pkg.Pos())
+		symbols[pkg.Pos()] = sel.Sel.Name
+		return false
+	})
+
+	var sels []selection
+	for pkgName, positions := range pkgRefs {
+		for _, pos := range positions {
+			sels = append(sels, selection{
+				pkgName: pkgName,
+				pos:     pos,
+				symbol:  symbols[pos],
+				method:  methods[pos],
+			})
+		}
+	}
+	return sels
+}
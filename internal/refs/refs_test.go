@@ -0,0 +1,94 @@
+package refs
+
+import (
+	"context"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"aslevy.com/go-doc/internal/godoc"
+	"aslevy.com/go-doc/internal/testutil"
+)
+
+func TestFind(t *testing.T) {
+	require := require.New(t)
+	root := t.TempDir()
+	testutil.WriteFiles(t, root, map[string]string{
+		"go.mod": "module example.com/m\n",
+		"api/api.go": `package api
+
+type Client struct{}
+
+func (Client) Do() {}
+
+func New() Client { return Client{} }
+`,
+		"cmd/tool/main.go": `package main
+
+import "example.com/m/api"
+
+func main() {
+	c := api.New()
+	c.Do()
+	do := api.Client.Do
+	do(c)
+}
+`,
+		"cmd/tool/main_test.go": `package main
+
+import client "example.com/m/api"
+
+var _ client.Client
+`,
+		"other/other.go": `package other
+
+import "example.com/m/other/api"
+
+// api is not example.com/m/api in this file.
+var _ = api.New()
+`,
+		"other/shadow.go": `package other
+
+func shadow() {
+	api := struct{ New int }{}
+	_ = api.New
+}
+`,
+		"testdata/api.go": `package testdata
+
+import "example.com/m/api"
+
+var _ = api.New()
+`,
+		"nested/go.mod": "module example.com/nested\n",
+		"nested/nested.go": `package nested
+
+import "example.com/m/api"
+
+var _ = api.New()
+`,
+	})
+
+	refs, err := Find(context.Background(),
+		[]godoc.PackageDir{godoc.NewPackageDir("example.com/m", root)},
+		"example.com/m/api")
+	require.NoError(err)
+
+	type ref struct {
+		importPath, file string
+		line             int
+		symbol, method   string
+	}
+	var got []ref
+	for _, r := range refs {
+		rel, err := filepath.Rel(root, r.Filename)
+		require.NoError(err)
+		got = append(got, ref{r.ImportPath, filepath.ToSlash(rel), r.Line, r.Symbol, r.Method})
+	}
+	require.Equal([]ref{
+		{"example.com/m/cmd/tool", "cmd/tool/main.go", 6, "New", ""},
+		{"example.com/m/cmd/tool", "cmd/tool/main.go", 8, "Client", "Do"},
+		{"example.com/m/cmd/tool", "cmd/tool/main_test.go", 5, "Client", ""},
+	}, got)
+}
@@ -0,0 +1,98 @@
+// Package suggest ranks package paths and symbols by their similarity to
+// a misspelled name, for "did you mean" suggestions.
+package suggest
+
+import (
+	"cmp"
+	"flag"
+	"slices"
+	"strings"
+	"unicode"
+)
+
+// Autocorrect causes go doc to show the docs of the best suggestion, instead
+// of failing, when nothing matches what was asked for.
+var Autocorrect bool
+
+func AddFlags(fs *flag.FlagSet) {
+	fs.BoolVar(&Autocorrect, "autocorrect", false, "show the docs of the closest match when no package or symbol matches")
+}
+
+// Distance returns the optimal string alignment distance between a and b,
+// ignoring case. This is the number of single rune insertions, deletions,
+// substitutions and transpositions of adjacent runes required to change a into
+// b, so that common typos like htpp for http have a distance of 1.
+func Distance(a, b string) int {
+	s, t := []rune(strings.ToLower(a)), []rune(strings.ToLower(b))
+	// d[i][j] is the distance between s[:i] and t[:j].
+	d := make([][]int, len(s)+1)
+	for i := range d {
+		d[i] = make([]int, len(t)+1)
+		d[i][0] = i
+	}
+	for j := range d[0] {
+		d[0][j] = j
+	}
+	for i := 1; i <= len(s); i++ {
+		for j := 1; j <= len(t); j++ {
+			cost := 1
+			if s[i-1] == t[j-1] {
+				cost = 0
+			}
+			d[i][j] = min(
+				d[i-1][j]+1,      // deletion
+				d[i][j-1]+1,      // insertion
+				d[i-1][j-1]+cost, // substitution
+			)
+			if i > 1 && j > 1 && s[i-1] == t[j-2] && s[i-2] == t[j-1] {
+				d[i][j] = min(d[i][j], d[i-2][j-2]+1) // transposition
+			}
+		}
+	}
+	return d[len(s)][len(t)]
+}
+
+// MaxDistance returns the largest Distance from name at which another name is
+// still a likely typo of it, rather than a different name altogether.
+func MaxDistance(name string) int {
+	return max(1, len([]rune(name))/3)
+}
+
+// PathDistance returns the Distance between path and the same number of
+// trailing segments of importPath, so that a partial path like htpp matches
+// net/http. If importPath has fewer segments than path, -1 is returned.
+func PathDistance(path, importPath string) int {
+	numParts := strings.Count(path, "/") + 1
+	parts := strings.Split(importPath, "/")
+	if len(parts) < numParts {
+		return -1
+	}
+	return Distance(path, strings.Join(parts[len(parts)-numParts:], "/"))
+}
+
+// IsIdentifier reports whether name could be a Go identifier, and so is worth
+// suggesting symbols for.
+func IsIdentifier(name string) bool {
+	if name == "" {
+		return false
+	}
+	for i, r := range name {
+		if r != '_' && !unicode.IsLetter(r) && (i == 0 || !unicode.IsDigit(r)) {
+			return false
+		}
+	}
+	return true
+}
+
+// Match is a suggestion and its distance from what was asked for.
+type Match[T any] struct {
+	Value    T
+	Distance int
+}
+
+// Sort sorts matches by ascending Distance, and otherwise retains their order.
+func Sort[T any](matches []Match[T]) {
+	slices.SortStableFunc(matches, func(a, b Match[T]) int {
+		return cmp.Compare(a.Distance, b.Distance)
+	})
+}
@@ -0,0 +1,56 @@
+package suggest
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestDistance(t *testing.T) {
+	tests := []struct {
+		a, b string
+		dist int
+	}{
+		{"", "", 0},
+		{"http", "http", 0},
+		{"HTTP", "http", 0},
+		{"htpp", "http", 1},
+		{"Clinet", "Client", 1},
+		{"Marshl", "Marshal", 1},
+		{"json", "jsno", 1},
+		{"", "abc", 3},
+		{"kitten", "sitting", 3},
+		{"ca", "abc", 3},
+	}
+	for _, test := range tests {
+		require.Equal(t, test.dist, Distance(test.a, test.b), "%q -> %q", test.a, test.b)
+		require.Equal(t, test.dist, Distance(test.b, test.a), "%q -> %q", test.b, test.a)
+	}
+}
+
+func TestPathDistance(t *testing.T) {
+	require.Equal(t, 1, PathDistance("htpp", "net/http"))
+	require.Equal(t, 1, PathDistance("net/htpp", "net/http"))
+	require.Equal(t, 0, PathDistance("json", "encoding/json"))
+	require.Equal(t, -1, PathDistance("a/b/c", "b/c"))
+}
+
+func TestMaxDistance(t *testing.T) {
+	require.Equal(t, 1, MaxDistance("io"))
+	require.Equal(t, 1, MaxDistance("http"))
+	require.Equal(t, 2, MaxDistance("Client"))
+}
+
+func TestIsIdentifier(t *testing.T) {
+	require.True(t, IsIdentifier("Client"))
+	require.True(t, IsIdentifier("_x1"))
+	require.False(t, IsIdentifier(""))
+	require.False(t, IsIdentifier("1x"))
+	require.False(t, IsIdentifier("net/http"))
+}
+
+func TestSort(t *testing.T) {
+	matches := []Match[string]{{"b", 2}, {"a", 1}, {"c", 2}, {"d", 1}}
+	Sort(matches)
+	require.Equal(t, []Match[string]{{"a", 1}, {"d", 1}, {"b", 2}, {"c", 2}}, matches)
+}
@@ -0,0 +1,21 @@
+// Package testutil provides helpers shared by the tests of other packages.
+package testutil
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+// WriteFiles writes the files, keyed by their slash separated path relative
+// to root, creating any directories they are in.
+func WriteFiles(t testing.TB, root string, files map[string]string) {
+	t.Helper()
+	for name, src := range files {
+		name = filepath.Join(root, filepath.FromSlash(name))
+		require.NoError(t, os.MkdirAll(filepath.Dir(name), 0755))
+		require.NoError(t, os.WriteFile(name, []byte(src), 0644))
+	}
+}
@@ -12,7 +12,44 @@ import (
 	"aslevy.com/go-doc/internal/godoc"
 )
 
-type ModulePackages map[godoc.PackageDir][]godoc.PackageDir
+// Module is a module listed in vendor/modules.txt.
+type Module struct {
+	// Path and Version are the module path and the version which is
+	// required. Version is empty if the line only records a replacement
+	// which applies to all versions of the module.
+	Path, Version string
+	// Dir is the directory of the module within the vendor directory.
+	Dir string
+
+	// ReplacePath and ReplaceVersion are the target of the replace
+	// directive of the module, if any. ReplaceVersion is empty if
+	// ReplacePath is a directory.
+	ReplacePath, ReplaceVersion string
+
+	// Explicit is true if the module is required by the go.mod file of the
+	// main module, and not just implied by the requirements of another
+	// module.
+	Explicit bool
+	// GoVersion is the go version declared by the go.mod file of the
+	// module, if any.
+	GoVersion string
+}
+
+// PackageDir returns the import path and vendored directory of the module.
+func (mod Module) PackageDir() godoc.PackageDir {
+	return godoc.NewPackageDir(mod.Path, mod.Dir)
+}
+
+// Replace returns the target of the replace directive of the module, formatted
+// as in go.mod, or the empty string if the module is not replaced.
+func (mod Module) Replace() string {
+	if mod.ReplaceVersion == "" {
+		return mod.ReplacePath
+	}
+	return mod.ReplacePath + " " + mod.ReplaceVersion
+}
+
+type ModulePackages map[Module][]godoc.PackageDir
 
 func ParseModulePackages(ctx context.Context, vendorDir string) (ModulePackages, error) {
 	modPkgs := make(ModulePackages)
@@ -20,13 +57,15 @@ func ParseModulePackages(ctx context.Context, vendorDir string) (ModulePackages,
 }
 
 func (modPkgs ModulePackages) parse(ctx context.Context, vendorDir string) error {
-	return Handler(func(_ context.Context, mod godoc.PackageDir, pkgs ...godoc.PackageDir) error {
+	return Handler(func(_ context.Context, mod Module, pkgs ...godoc.PackageDir) error {
 		modPkgs[mod] = append(modPkgs[mod], pkgs...)
 		return nil
 	}).parse(ctx, vendorDir)
 }
 
-type Handler func(ctx context.Context, mod godoc.PackageDir, pkgs ...godoc.PackageDir) error
+// Handler is called with each module listed in vendor/modules.txt, and its
+// vendored packages, if any.
+type Handler func(ctx context.Context, mod Module, pkgs ...godoc.PackageDir) error
 
 func Parse(ctx context.Context, vendorDir string, handle Handler) error {
 	return handle.parse(ctx, vendorDir)
@@ -44,55 +83,103 @@ func (handle Handler) parse(ctx context.Context, vendorDir string) error {
 	return handle.parseData(ctx, vendorDir, modTxtFile)
 }
 func (handle Handler) parseData(ctx context.Context, vendorDir string, data io.Reader) error {
-	var mod godoc.PackageDir
+	var mod Module
 	var pkgs []godoc.PackageDir
+	// The annotations of a module follow its module line, so a module is
+	// only handled once the next module line, or the end, is reached.
+	handleModule := func() error {
+		if mod.Path == "" {
+			return nil
+		}
+		return handle(ctx, mod, pkgs...)
+	}
 	lines := bufio.NewScanner(data)
 	for lines.Scan() && ctx.Err() == nil {
-		modImportPath, _, pkgImportPath := parseLine(lines.Text())
-		if modImportPath != "" {
-			if mod.Dir != "" {
-				if err := handle(ctx, mod, pkgs...); err != nil {
-					return err
-				}
+		line := lines.Text()
+		switch {
+		case strings.HasPrefix(line, "## "):
+			if mod.Path == "" {
+				// Annotations of the vendor directory itself, like
+				// "## workspace", precede the first module.
+				continue
 			}
-			mod = godoc.NewPackageDir(
-				modImportPath,
-				filepath.Join(vendorDir, modImportPath),
-			)
+			parseAnnotations(&mod, line)
+		case strings.HasPrefix(line, "# "):
+			if err := handleModule(); err != nil {
+				return err
+			}
+			var err error
+			mod, err = parseModuleLine(line)
+			if err != nil {
+				return err
+			}
+			mod.Dir = filepath.Join(vendorDir, filepath.FromSlash(mod.Path))
 			pkgs = pkgs[:0]
-			continue
-		}
-		if pkgImportPath != "" {
-			if mod.Dir == "" {
+		default:
+			pkgImportPath := strings.TrimSpace(line)
+			if pkgImportPath == "" {
+				continue
+			}
+			if mod.Path == "" {
 				return fmt.Errorf("found package %q before a module", pkgImportPath)
 			}
-			if !strings.HasPrefix(pkgImportPath, mod.ImportPath) {
-				return fmt.Errorf("package %q is not in module %q", pkgImportPath, mod.ImportPath)
+			if !strings.HasPrefix(pkgImportPath, mod.Path) {
+				return fmt.Errorf("package %q is not in module %q", pkgImportPath, mod.Path)
 			}
 			pkgs = append(pkgs, godoc.NewPackageDir(pkgImportPath, ""))
 		}
 	}
-	return nil
+	if err := lines.Err(); err != nil {
+		return err
+	}
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	return handleModule()
 }
-func parseLine(line string) (modImportPath, modVersion, pkgImportPath string) {
-	fields := strings.Fields(line)
+
+// parseModuleLine parses a module line, which is one of:
+//
+//	# path version
+//	# path version => replacePath [replaceVersion]
+//	# path => replacePath [replaceVersion]
+func parseModuleLine(line string) (Module, error) {
+	fields := strings.Fields(strings.TrimPrefix(line, "# "))
+	var mod Module
 	if len(fields) == 0 {
-		return
+		return mod, fmt.Errorf("invalid module line %q", line)
 	}
-	switch fields[0] {
-	case "#":
-		// module
-		if len(fields) < 3 {
-			return
-		}
-		modImportPath, modVersion = fields[1], fields[2]
-		if !strings.HasPrefix(modVersion, "v") {
-			modVersion = ""
+	mod.Path, fields = fields[0], fields[1:]
+	if len(fields) > 0 && fields[0] != "=>" {
+		mod.Version, fields = fields[0], fields[1:]
+	}
+	if len(fields) == 0 {
+		return mod, nil
+	}
+	if fields[0] != "=>" || len(fields) < 2 || len(fields) > 3 {
+		return mod, fmt.Errorf("invalid module line %q", line)
+	}
+	mod.ReplacePath = fields[1]
+	if len(fields) == 3 {
+		mod.ReplaceVersion = fields[2]
+	}
+	return mod, nil
+}
+
+// parseAnnotations records the annotations of an annotation line, such as
+//
+//	## explicit; go 1.19
+//
+// in mod. Unknown annotations are ignored, as they may be added by later
+// versions of Go.
+func parseAnnotations(mod *Module, line string) {
+	for _, annotation := range strings.Split(strings.TrimPrefix(line, "## "), ";") {
+		annotation = strings.TrimSpace(annotation)
+		switch {
+		case annotation == "explicit":
+			mod.Explicit = true
+		case strings.HasPrefix(annotation, "go "):
+			mod.GoVersion = strings.TrimSpace(strings.TrimPrefix(annotation, "go "))
 		}
-	case "##":
-		// ignore
-	default:
-		pkgImportPath = fields[0]
 	}
-	return
 }
@@ -5,6 +5,7 @@ import (
 	"os"
 	"os/exec"
 	"path/filepath"
+	"strings"
 	"testing"
 
 	"aslevy.com/go-doc/internal/benchmark"
@@ -19,7 +20,94 @@ func TestParseVendoredModules(t *testing.T) {
 	ctx := context.Background()
 	modPkgs, err := ParseModulePackages(ctx, testVendorDir)
 	require.NoError(t, err)
-	require.Equal(t, vendoredModules, modPkgs)
+	// The annotations depend on the go.mod files of the dependencies, so
+	// they are covered by TestParseData instead.
+	got := make(ModulePackages, len(modPkgs))
+	for mod, pkgs := range modPkgs {
+		mod.Explicit, mod.GoVersion = false, ""
+		got[mod] = pkgs
+	}
+	require.Equal(t, vendoredModules, got)
+}
+
+func TestParseData(t *testing.T) {
+	const modulesTxt = `# example.com/a v1.2.3
+## explicit; go 1.19
+example.com/a
+example.com/a/b
+# example.com/c v0.1.0 => example.com/fork v0.2.0
+## explicit
+example.com/c
+# example.com/d v0.0.0-20230101000000-abcdefabcdef => ../d
+## go 1.21; unknown
+example.com/d/e
+# example.com/f => ./f
+## explicit
+`
+	ctx := context.Background()
+	modPkgs := make(ModulePackages)
+	err := Handler(func(_ context.Context, mod Module, pkgs ...godoc.PackageDir) error {
+		modPkgs[mod] = append(modPkgs[mod], pkgs...)
+		return nil
+	}).parseData(ctx, "vendor", strings.NewReader(modulesTxt))
+	require.NoError(t, err)
+	require.Equal(t, ModulePackages{
+		{
+			Path:      "example.com/a",
+			Version:   "v1.2.3",
+			Dir:       filepath.FromSlash("vendor/example.com/a"),
+			Explicit:  true,
+			GoVersion: "1.19",
+		}: testPackages("example.com/a", "example.com/a/b"),
+		{
+			Path:           "example.com/c",
+			Version:        "v0.1.0",
+			Dir:            filepath.FromSlash("vendor/example.com/c"),
+			ReplacePath:    "example.com/fork",
+			ReplaceVersion: "v0.2.0",
+			Explicit:       true,
+		}: testPackages("example.com/c"),
+		{
+			Path:        "example.com/d",
+			Version:     "v0.0.0-20230101000000-abcdefabcdef",
+			Dir:         filepath.FromSlash("vendor/example.com/d"),
+			ReplacePath: "../d",
+			GoVersion:   "1.21",
+		}: testPackages("example.com/d/e"),
+		{
+			Path:        "example.com/f",
+			Dir:         filepath.FromSlash("vendor/example.com/f"),
+			ReplacePath: "./f",
+			Explicit:    true,
+		}: nil,
+	}, modPkgs)
+
+	err = Handler(func(context.Context, Module, ...godoc.PackageDir) error { return nil }).
+		parseData(ctx, "vendor", strings.NewReader("# example.com/a v1.0.0 =>\n"))
+	require.ErrorContains(t, err, "invalid module line")
+
+	// Go writes a workspace annotation before the first module of the
+	// vendor directory of a workspace.
+	modPkgs = make(ModulePackages)
+	err = Handler(func(_ context.Context, mod Module, pkgs ...godoc.PackageDir) error {
+		modPkgs[mod] = append(modPkgs[mod], pkgs...)
+		return nil
+	}).parseData(ctx, "vendor", strings.NewReader("## workspace\n# example.com/a v1.2.3\n## explicit\nexample.com/a\n"))
+	require.NoError(t, err)
+	require.Equal(t, ModulePackages{
+		{
+			Path:     "example.com/a",
+			Version:  "v1.2.3",
+			Dir:      filepath.FromSlash("vendor/example.com/a"),
+			Explicit: true,
+		}: testPackages("example.com/a"),
+	}, modPkgs)
+}
+
+func TestModuleReplace(t *testing.T) {
+	require.Equal(t, "", Module{Path: "example.com/a"}.Replace())
+	require.Equal(t, "../a", Module{ReplacePath: "../a"}.Replace())
+	require.Equal(t, "example.com/fork v0.2.0", Module{ReplacePath: "example.com/fork", ReplaceVersion: "v0.2.0"}.Replace())
 }
 
 type T interface {
@@ -38,10 +126,12 @@ func goModVendor(t T, vendorDir string) {
 }
 
 var vendoredModules = ModulePackages{
-	testModule("aslevy.com/go-doc"): testPackages(
+	testReplacedModule("aslevy.com/go-doc", "v0.0.0-20211002150000-000000000000", "../../../../"): testPackages(
 		"aslevy.com/go-doc/testdata/codeblocks",
 	),
-	testModule("github.com/alecthomas/chroma"): testPackages(
+	// The replacement of all versions is recorded separately.
+	testReplacedModule("aslevy.com/go-doc", "", "../../../../"): nil,
+	testModule("github.com/alecthomas/chroma", "v0.10.0"): testPackages(
 		"github.com/alecthomas/chroma",
 		"github.com/alecthomas/chroma/formatters",
 		"github.com/alecthomas/chroma/formatters/html",
@@ -77,56 +167,56 @@ var vendoredModules = ModulePackages{
 		"github.com/alecthomas/chroma/quick",
 		"github.com/alecthomas/chroma/styles",
 	),
-	testModule("github.com/aymanbagabas/go-osc52"): testPackages(
+	testModule("github.com/aymanbagabas/go-osc52", "v1.2.1"): testPackages(
 		"github.com/aymanbagabas/go-osc52",
 	),
-	testModule("github.com/aymerick/douceur"): testPackages(
+	testModule("github.com/aymerick/douceur", "v0.2.0"): testPackages(
 		"github.com/aymerick/douceur/css",
 		"github.com/aymerick/douceur/parser",
 	),
-	testModule("github.com/charmbracelet/glamour"): testPackages(
+	testModule("github.com/charmbracelet/glamour", "v0.6.1-0.20221114002222-bf21e0bca6f3"): testPackages(
 		"github.com/charmbracelet/glamour",
 		"github.com/charmbracelet/glamour/ansi",
 	),
-	testModule("github.com/davecgh/go-spew"): testPackages(
+	testModule("github.com/davecgh/go-spew", "v1.1.1"): testPackages(
 		"github.com/davecgh/go-spew/spew",
 	),
-	testModule("github.com/dlclark/regexp2"): testPackages(
+	testModule("github.com/dlclark/regexp2", "v1.7.0"): testPackages(
 		"github.com/dlclark/regexp2",
 		"github.com/dlclark/regexp2/syntax",
 	),
-	testModule("github.com/gorilla/css"): testPackages(
+	testModule("github.com/gorilla/css", "v1.0.0"): testPackages(
 		"github.com/gorilla/css/scanner",
 	),
-	testModule("github.com/lucasb-eyer/go-colorful"): testPackages(
+	testModule("github.com/lucasb-eyer/go-colorful", "v1.2.0"): testPackages(
 		"github.com/lucasb-eyer/go-colorful",
 	),
-	testModule("github.com/mattn/go-isatty"): testPackages(
+	testModule("github.com/mattn/go-isatty", "v0.0.17"): testPackages(
 		"github.com/mattn/go-isatty",
 	),
-	testModule("github.com/mattn/go-runewidth"): testPackages(
+	testModule("github.com/mattn/go-runewidth", "v0.0.14"): testPackages(
 		"github.com/mattn/go-runewidth",
 	),
-	testModule("github.com/microcosm-cc/bluemonday"): testPackages(
+	testModule("github.com/microcosm-cc/bluemonday", "v1.0.21"): testPackages(
 		"github.com/microcosm-cc/bluemonday",
 		"github.com/microcosm-cc/bluemonday/css",
 	),
-	testModule("github.com/muesli/reflow"): testPackages(
+	testModule("github.com/muesli/reflow", "v0.3.0"): testPackages(
 		"github.com/muesli/reflow/ansi",
 		"github.com/muesli/reflow/indent",
 		"github.com/muesli/reflow/padding",
 		"github.com/muesli/reflow/wordwrap",
 	),
-	testModule("github.com/muesli/termenv"): testPackages(
+	testModule("github.com/muesli/termenv", "v0.13.0"): testPackages(
 		"github.com/muesli/termenv",
 	),
-	testModule("github.com/olekukonko/tablewriter"): testPackages(
+	testModule("github.com/olekukonko/tablewriter", "v0.0.5"): testPackages(
 		"github.com/olekukonko/tablewriter",
 	),
-	testModule("github.com/rivo/uniseg"): testPackages(
+	testModule("github.com/rivo/uniseg", "v0.4.3"): testPackages(
 		"github.com/rivo/uniseg",
 	),
-	testModule("github.com/yuin/goldmark"): testPackages(
+	testModule("github.com/yuin/goldmark", "v1.5.3"): testPackages(
 		"github.com/yuin/goldmark",
 		"github.com/yuin/goldmark/ast",
 		"github.com/yuin/goldmark/extension",
@@ -137,24 +227,33 @@ var vendoredModules = ModulePackages{
 		"github.com/yuin/goldmark/text",
 		"github.com/yuin/goldmark/util",
 	),
-	testModule("github.com/yuin/goldmark-emoji"): testPackages(
+	testModule("github.com/yuin/goldmark-emoji", "v1.0.1"): testPackages(
 		"github.com/yuin/goldmark-emoji",
 		"github.com/yuin/goldmark-emoji/ast",
 		"github.com/yuin/goldmark-emoji/definition",
 	),
-	testModule("golang.org/x/net"): testPackages(
+	testModule("golang.org/x/net", "v0.4.0"): testPackages(
 		"golang.org/x/net/html",
 		"golang.org/x/net/html/atom",
 	),
-	testModule("golang.org/x/sys"): testPackages(
+	testModule("golang.org/x/sys", "v0.4.0"): testPackages(
 		"golang.org/x/sys/internal/unsafeheader",
 		"golang.org/x/sys/unix",
 		"golang.org/x/sys/windows",
 	),
 }
 
-func testModule(importPath string) godoc.PackageDir {
-	return godoc.NewPackageDir(importPath, filepath.Join(testVendorDir, filepath.FromSlash(importPath)))
+func testModule(importPath, version string) Module {
+	return Module{
+		Path:    importPath,
+		Version: version,
+		Dir:     filepath.Join(testVendorDir, filepath.FromSlash(importPath)),
+	}
+}
+func testReplacedModule(importPath, version, replacePath string) Module {
+	mod := testModule(importPath, version)
+	mod.ReplacePath = replacePath
+	return mod
 }
 func testPackages(importPaths ...string) []godoc.PackageDir {
 	pkgs := make([]godoc.PackageDir, len(importPaths))
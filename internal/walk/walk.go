@@ -0,0 +1,194 @@
+// Package walk provides a concurrent breadth-first walk of a directory tree
+// which yields results in the same order as a sequential walk.
+package walk
+
+import (
+	"context"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"runtime"
+	"strings"
+
+	"golang.org/x/sync/errgroup"
+
+	"aslevy.com/go-doc/internal/godoc"
+)
+
+// DefaultWorkers is the default number of directories which may be read
+// concurrently.
+var DefaultWorkers = 2 * runtime.GOMAXPROCS(0)
+
+// BFS walks the tree rooted at root in breadth-first order.
+//
+// The read func is called concurrently for up to workers directories at a
+// time, and should do any slow work, like reading the directory from the
+// filesystem.
+//
+// The visit func is called from a single goroutine with the result of read
+// for each directory, in breadth-first order, and returns the subdirectories
+// of the directory to walk. The order of the walk is determined solely by the
+// order of the subdirectories returned by visit, so it is the same as that of
+// a sequential walk.
+//
+// If workers is less than one, DefaultWorkers is used.
+//
+// BFS returns the first error returned by visit, or ctx.Err() if ctx is
+// cancelled.
+func BFS[D, R any](ctx context.Context, workers int, root D,
+	read func(ctx context.Context, dir D) R,
+	visit func(dir D, res R) ([]D, error),
+) error {
+	if workers < 1 {
+		workers = DefaultWorkers
+	}
+
+	ctx, cancel := context.WithCancel(ctx)
+	g, ctx := errgroup.WithContext(ctx)
+	g.SetLimit(workers)
+	defer func() {
+		cancel()
+		g.Wait()
+	}()
+
+	type result struct {
+		dir  D
+		res  R
+		done chan struct{}
+	}
+	start := func(dir D) *result {
+		r := &result{dir: dir, done: make(chan struct{})}
+		g.Go(func() error {
+			defer close(r.done)
+			if ctx.Err() == nil {
+				r.res = read(ctx, dir)
+			}
+			return nil
+		})
+		return r
+	}
+
+	// queue holds the directories which have been started but not yet
+	// visited, in breadth-first order.
+	queue := []*result{start(root)}
+	for len(queue) > 0 {
+		r := queue[0]
+		queue[0] = nil
+		queue = queue[1:]
+
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-r.done:
+		}
+		subDirs, err := visit(r.dir, r.res)
+		if err != nil {
+			return err
+		}
+		for _, subDir := range subDirs {
+			queue = append(queue, start(subDir))
+		}
+	}
+	return nil
+}
+
+// Dir is the result of ReadDir.
+type Dir struct {
+	// HasGoFiles is true if the directory contains any .go files.
+	HasGoFiles bool
+	// SubDirs are the names of the subdirectories which may contain
+	// packages, in the order they were read.
+	SubDirs []string
+}
+
+// ReadDir reads dir and returns whether it has any .go files and which of its
+// subdirectories may contain packages.
+//
+// Like the go tool, it ignores directories starting with ., _, or named
+// testdata. If inModule is true, it also ignores vendor directories and
+// stops at module boundaries.
+func ReadDir(dir string, inModule bool) (Dir, error) {
+	fd, err := os.Open(dir)
+	if err != nil {
+		return Dir{}, err
+	}
+	entries, err := fd.Readdir(0)
+	fd.Close()
+	if err != nil {
+		return Dir{}, err
+	}
+
+	var d Dir
+	for _, entry := range entries {
+		name := entry.Name()
+		// For plain files, remember if this directory contains any .go
+		// source files, but ignore them otherwise.
+		if !entry.IsDir() {
+			if !d.HasGoFiles && strings.HasSuffix(name, ".go") {
+				d.HasGoFiles = true
+			}
+			continue
+		}
+		// Entry is a directory.
+
+		if Ignored(filepath.Join(dir, name), inModule) {
+			continue
+		}
+		// Remember this directory for the next pass.
+		d.SubDirs = append(d.SubDirs, name)
+	}
+	return d, nil
+}
+
+// Ignored reports whether the go tool ignores the directory dir when listing
+// packages: it starts with ., _, or is named testdata. If inModule is true,
+// vendor directories and the roots of nested modules are also ignored.
+func Ignored(dir string, inModule bool) bool {
+	// The go tool ignores directories starting with ., _, or named "testdata".
+	name := filepath.Base(dir)
+	if name[0] == '.' || name[0] == '_' || name == "testdata" {
+		return true
+	}
+	// When in a module, ignore vendor directories and stop at module boundaries.
+	if inModule {
+		if name == "vendor" {
+			return true
+		}
+		if fi, err := os.Stat(filepath.Join(dir, "go.mod")); err == nil && !fi.IsDir() {
+			return true
+		}
+	}
+	return false
+}
+
+// Module calls fn with the root of a module and every directory within it which
+// may hold one of its packages, in lexical order, along with their import
+// paths. Directories which are Ignored are skipped.
+//
+// Subdirectories which cannot be read are skipped. Module returns any error
+// reading root, or returned by fn, or ctx.Err() if ctx is cancelled.
+func Module(ctx context.Context, root godoc.PackageDir, fn func(pkg godoc.PackageDir) error) error {
+	return filepath.WalkDir(root.Dir, func(dir string, d fs.DirEntry, err error) error {
+		if err != nil {
+			if dir == root.Dir {
+				return err
+			}
+			return nil
+		}
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		if !d.IsDir() {
+			return nil
+		}
+		if dir != root.Dir && Ignored(dir, true) {
+			return filepath.SkipDir
+		}
+		rel, err := filepath.Rel(root.Dir, dir)
+		if err != nil {
+			return err
+		}
+		return fn(godoc.NewPackageDir(path.Join(root.ImportPath, filepath.ToSlash(rel)), dir))
+	})
+}
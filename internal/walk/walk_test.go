@@ -0,0 +1,95 @@
+package walk
+
+import (
+	"context"
+	"errors"
+	"io/fs"
+	"math/rand"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"aslevy.com/go-doc/internal/godoc"
+)
+
+// tree is a directory tree with each directory's subdirectories.
+var tree = map[string][]string{
+	"":      {"a", "b", "c"},
+	"a":     {"a/a", "a/b"},
+	"b":     {"b/a"},
+	"c":     {"c/a", "c/b", "c/c"},
+	"a/a":   {"a/a/a"},
+	"b/a":   {"b/a/a", "b/a/b"},
+	"c/c":   {"c/c/a"},
+	"a/a/a": {"a/a/a/a"},
+}
+
+func TestBFS(t *testing.T) {
+	expected := []string{
+		"",
+		"a", "b", "c",
+		"a/a", "a/b", "b/a", "c/a", "c/b", "c/c",
+		"a/a/a", "b/a/a", "b/a/b", "c/c/a",
+		"a/a/a/a",
+	}
+	for _, workers := range []int{1, 2, 16} {
+		var visited []string
+		err := BFS(context.Background(), workers, "",
+			func(_ context.Context, dir string) []string {
+				// Finish reads out of order.
+				time.Sleep(time.Duration(rand.Intn(1000)) * time.Microsecond)
+				return tree[dir]
+			},
+			func(dir string, subDirs []string) ([]string, error) {
+				visited = append(visited, dir)
+				return subDirs, nil
+			})
+		require.NoError(t, err)
+		require.Equal(t, expected, visited, "workers: %d", workers)
+	}
+}
+
+func TestBFS_error(t *testing.T) {
+	errStop := errors.New("stop")
+	var visited []string
+	err := BFS(context.Background(), 0, "",
+		func(_ context.Context, dir string) []string { return tree[dir] },
+		func(dir string, subDirs []string) ([]string, error) {
+			if dir == "b" {
+				return nil, errStop
+			}
+			visited = append(visited, dir)
+			return subDirs, nil
+		})
+	require.ErrorIs(t, err, errStop)
+	require.Equal(t, []string{"", "a"}, visited)
+}
+
+func TestModule(t *testing.T) {
+	root := t.TempDir()
+	for _, dir := range []string{
+		"a/b", "_ignored", ".hidden", "testdata", "vendor/example.com/v", "nested/pkg",
+	} {
+		require.NoError(t, os.MkdirAll(filepath.Join(root, filepath.FromSlash(dir)), 0755))
+	}
+	require.NoError(t, os.WriteFile(filepath.Join(root, "nested", "go.mod"), []byte("module example.com/nested\n"), 0644))
+
+	var visited []godoc.PackageDir
+	err := Module(context.Background(), godoc.NewPackageDir("example.com/m", root), func(pkg godoc.PackageDir) error {
+		visited = append(visited, pkg)
+		return nil
+	})
+	require.NoError(t, err)
+	require.Equal(t, []godoc.PackageDir{
+		godoc.NewPackageDir("example.com/m", root),
+		godoc.NewPackageDir("example.com/m/a", filepath.Join(root, "a")),
+		godoc.NewPackageDir("example.com/m/a/b", filepath.Join(root, "a", "b")),
+	}, visited)
+
+	err = Module(context.Background(), godoc.NewPackageDir("example.com/m", filepath.Join(root, "missing")),
+		func(godoc.PackageDir) error { return nil })
+	require.ErrorIs(t, err, fs.ErrNotExist)
+}
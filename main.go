@@ -57,11 +57,13 @@ import (
 	"strings"
 
 	"aslevy.com/go-doc/internal/completion"
+	"aslevy.com/go-doc/internal/daemon"
 	"aslevy.com/go-doc/internal/dlog"
 	"aslevy.com/go-doc/internal/flags"
 	"aslevy.com/go-doc/internal/godoc"
 	"aslevy.com/go-doc/internal/index"
 	"aslevy.com/go-doc/internal/outfmt"
+	"aslevy.com/go-doc/internal/refs"
 )
 
 var (
@@ -93,6 +95,9 @@ func usage() {
 func main() {
 	log.SetFlags(0)
 	log.SetPrefix("doc: ")
+	if sendToDaemon(os.Stdout, os.Args[1:]) {
+		return
+	}
 	dirsInit()
 	err := do(os.Stdout, flag.CommandLine, os.Args[1:])
 	if err != nil {
@@ -118,12 +123,17 @@ func do(writer io.Writer, flagSet *flag.FlagSet, args []string) (err error) {
 			return err
 		}
 	}
+	if daemon.Requested {
+		return serve()
+	}
 
 	godoc.NoImports = godoc.NoImports || short // don't show imports with -short
-	if pkgIdx := packageIndex(); pkgIdx != nil {
-		defer pkgIdx.Close()
+	pkgIdx := packageIndex()
+	if pkgIdx != nil {
+		defer closePackageIndex(pkgIdx)
 		xdirs = index.NewDirs(pkgIdx)
 	}
+	defer suggestOnError(&err, writer, flagSet, pkgIdx)
 	completer := completion.NewCompleter(writer, xdirs, unexported, matchCase, flagSet.Args())
 
 	// Set up pager and output format writers.
@@ -131,6 +141,16 @@ func do(writer io.Writer, flagSet *flag.FlagSet, args []string) (err error) {
 	defer wc.Close()
 	writer = wc
 
+	if index.Which != "" {
+		return printWhich(writer, pkgIdx, index.Which)
+	}
+	if index.SearchQuery != "" {
+		return printSearch(writer, pkgIdx, index.SearchQuery)
+	}
+	if index.IsCommand() {
+		return runIndexCommands(writer, pkgIdx)
+	}
+
 	var paths []string
 	var symbol, method string
 	// Loop until something is printed.
@@ -180,6 +200,10 @@ func do(writer io.Writer, flagSet *flag.FlagSet, args []string) (err error) {
 		}()
 
 		switch {
+		case refs.Requested:
+			if pkg.printRefs(symbol, method) {
+				return
+			}
 		case symbol == "":
 			pkg.packageDoc() // The package exists, so we got some output.
 			return
@@ -228,7 +252,7 @@ func failMessage(paths []string, symbol, method string) error {
 func parseArgs(args []string) (pkg *build.Package, path, symbol string, more bool) {
 	wd, err := os.Getwd()
 	if err != nil {
-		log.Fatal(err)
+		fatal(err)
 	}
 	if len(args) == 0 {
 		// Easy: current directory.
@@ -340,9 +364,9 @@ func parseArgs(args []string) (pkg *build.Package, path, symbol string, more boo
 		// instead of go/build.
 		importErrStr := importErr.Error()
 		if strings.Contains(importErrStr, arg[:period]) {
-			log.Fatal(importErrStr)
+			panic(PackageError(importErrStr))
 		} else {
-			log.Fatalf("no such package %s: %s", arg[:period], importErrStr)
+			panic(PackageError(fmt.Sprintf("no such package %s: %s", arg[:period], importErrStr)))
 		}
 	}
 
@@ -382,7 +406,7 @@ func importDir(dir string) *build.Package {
 		if completion.Requested {
 			return nil
 		}
-		log.Fatal(err)
+		panic(PackageError(err.Error()))
 	}
 	return pkg
 }
@@ -436,7 +460,7 @@ func findNextPackage(pkg string) (string, bool) {
 		dlog.Println("findNextPackage", pkg, d, ok)
 		return d.Dir, ok
 	} else if !errors.Is(err, godoc.ErrFilterNotSupported) {
-		log.Fatalf("error filtering package import paths: %v", err)
+		fatalf("error filtering package import paths: %v", err)
 	}
 
 	pkgSuffix := "/" + pkg
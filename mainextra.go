@@ -3,34 +3,280 @@ package main
 import (
 	"bytes"
 	"context"
+	"crypto/sha256"
+	"errors"
+	"flag"
+	"fmt"
+	"go/ast"
+	"go/build"
+	"go/doc"
+	"io"
+	"log"
 	"os"
 	"os/exec"
 	"path/filepath"
 	"strings"
+	"text/tabwriter"
+	"time"
 
+	"aslevy.com/go-doc/internal/completion"
+	"aslevy.com/go-doc/internal/daemon"
 	"aslevy.com/go-doc/internal/dlog"
 	"aslevy.com/go-doc/internal/godoc"
 	"aslevy.com/go-doc/internal/index"
+	"aslevy.com/go-doc/internal/outfmt"
+	"aslevy.com/go-doc/internal/pager"
+	"aslevy.com/go-doc/internal/suggest"
+	"aslevy.com/go-doc/internal/workdir"
 )
 
+// packageIndex returns the package index of the current module or workspace,
+// which a daemon keeps open between requests until its module files change,
+// and resyncs when its local packages change.
 func packageIndex() *index.Index {
-	localModuleRoot := moduleRootDir(goCmd())
-	if localModuleRoot == "" {
-		return nil
+	if !served.serving {
+		return loadPackageIndex()
 	}
-	path := indexCachePath(localModuleRoot)
-	if err := os.Mkdir(filepath.Dir(path), 0755); err != nil && !os.IsExist(err) {
-		dlog.Printf("failed to create index cache dir: %v", err)
-		return nil
+	if served.pkgIdx != nil && served.modCache == index.ModCache {
+		if !served.pkgIdx.ModuleFilesChanged() {
+			if err := served.pkgIdx.Resync(context.Background()); err != nil {
+				dlog.Printf("failed to resync the index: %v", err)
+			}
+			if wd, err := workdir.Get(); err == nil {
+				served.pkgIdx.SetCurrentDir(wd)
+			}
+			return served.pkgIdx
+		}
+		// The requirements may have changed, and with them the code
+		// roots.
+		dlog.Printf("module files changed, reloading the index")
+		resetCodeRoots()
+	}
+	if served.pkgIdx != nil {
+		served.pkgIdx.Close()
+	}
+	served.pkgIdx, served.modCache = loadPackageIndex(), index.ModCache
+	return served.pkgIdx
+}
+
+// fatalf is like log.Fatalf, except while serving, where it only fails the
+// request, so that a bad package or a client which hung up does not stop the
+// daemon. See suggestOnError.
+func fatalf(format string, args ...any) {
+	if served.serving {
+		panic(fatalError{fmt.Errorf(format, args...)})
+	}
+	log.Fatalf(format, args...)
+}
+func fatal(args ...any) { fatalf("%s", fmt.Sprint(args...)) }
+
+// fatalError is the panic value of fatalf while serving.
+type fatalError struct{ error }
+
+// closePackageIndex closes pkgIdx, unless it is kept open by a daemon.
+func closePackageIndex(pkgIdx *index.Index) {
+	if served.serving {
+		return
+	}
+	pkgIdx.Close()
+}
+
+func loadPackageIndex() *index.Index {
+	// The code roots determine whether modules are used.
+	codeRoots := dirsToIndexModules(codeRoots()...)
+	path, projectRoot := packageIndexPath()
+	mode := index.Sync
+	if index.IsCommand() && !index.SyncOnly {
+		// The commands report on or sync the index themselves.
+		mode = index.ModeSkipSync
+	}
+	opts := []index.Option{
+		index.WithMode(mode),
+		index.WithSymbolLoader(loadSymbols),
+	}
+	if !index.IsCommand() {
+		opts = append(opts, index.WithDetachedSync(index.DetachedSync(detachedSyncArgs()...)))
+	}
+	if gowork := goWork(); gowork != "" {
+		opts = append(opts, index.WithGoWork(gowork))
+	}
+	if sharedPath := sharedIndexCachePath(); sharedPath != "" {
+		opts = append(opts, index.WithSharedIndex(sharedPath))
+	}
+	if wd, err := workdir.Get(); err == nil {
+		opts = append(opts, index.WithCurrentDir(wd))
+	}
+	switch index.Progress {
+	case index.ProgressJSON:
+		opts = append(opts, index.WithNoProgressBar(), index.WithProgress(index.JSONProgress(os.Stderr)))
+	case index.ProgressOff:
+		opts = append(opts, index.WithNoProgressBar())
+	}
+	if index.ModCache {
+		if modCache := goModCache(); modCache != "" {
+			opts = append(opts, index.WithModCache(modCache))
+		}
+	}
+	if projectRoot != "" {
+		if pins, err := index.ReadPinsFile(filepath.Join(projectRoot, index.PinsFile)); err != nil {
+			dlog.Printf("failed to read pins: %v", err)
+		} else {
+			opts = append(opts, index.WithPins(pins))
+		}
+	}
+
+	ctx := context.Background()
+	pkgIdx, err := index.Load(ctx, path, codeRoots, opts...)
+	if err != nil && path != index.InMemory {
+		log.Printf("warning: failed to load the package index %q, using an in-memory index: %v", path, err)
+		pkgIdx, err = index.Load(ctx, index.InMemory, codeRoots, opts...)
 	}
-	pkgIdx, err := index.Load(context.Background(), path, dirsToIndexModules(codeRoots()...), index.WithMode(index.Sync))
 	if err != nil {
 		dlog.Printf("index.Load: %v", err)
 	}
 	return pkgIdx
 }
-func indexCachePath(localModuleRoot string) string {
-	return filepath.Join(localModuleRoot, ".go-doc", "packages.sqlite3")
+
+// packageIndexPath returns the path to the index of the current module or
+// workspace, and its root directory. Outside of any module, or in GOPATH mode,
+// projectRoot is empty and the index is global. See globalIndexCachePath.
+func packageIndexPath() (path, projectRoot string) {
+	if gowork := goWork(); gowork != "" {
+		projectRoot = filepath.Dir(gowork)
+		return indexCachePath(projectRoot, filepath.Base(gowork)+".sqlite3"), projectRoot
+	}
+	if projectRoot = moduleRootDir(goCmd()); projectRoot != "" {
+		return indexCachePath(projectRoot, "packages.sqlite3"), projectRoot
+	}
+	return globalIndexCachePath(), ""
+}
+
+// detachedSyncArgs returns the args for the detached process which syncs the
+// index, which must agree with this one about which modules are indexed.
+func detachedSyncArgs() []string {
+	return []string{
+		"-index-sync",
+		"-index-progress=" + index.ProgressOff,
+		fmt.Sprintf("-index-modcache=%t", index.ModCache),
+	}
+}
+
+// indexCachePath returns the path to the index of the module or workspace
+// rooted at projectRoot.
+//
+// If GODOC_INDEX_DIR is set, the index is stored there, named for a hash of
+// projectRoot and name. Otherwise it is stored as name in the .go-doc directory
+// of projectRoot, or if that is not writable, in the user cache dir. If no
+// directory is writable, index.InMemory is returned.
+//
+// This runs on every completion, so falling back is only logged with
+// -debug-index.
+func indexCachePath(projectRoot, name string) string {
+	if dir := os.Getenv(index.DirEnvVar); dir != "" {
+		if err := index.WritableDir(dir); err != nil {
+			dlog.Printf("%s is not writable, using an in-memory index: %v", index.DirEnvVar, err)
+			return index.InMemory
+		}
+		return filepath.Join(dir, hashedIndexName(projectRoot, name))
+	}
+
+	dir := filepath.Join(projectRoot, ".go-doc")
+	err := index.WritableDir(dir)
+	if err == nil {
+		ignoreIndexDir(dir)
+		return filepath.Join(dir, name)
+	}
+	dlog.Printf("%v", err)
+
+	if dir := userIndexCacheDir(); dir != "" {
+		dlog.Printf("storing the package index in %s, set %s to choose another directory", dir, index.DirEnvVar)
+		return filepath.Join(dir, hashedIndexName(projectRoot, name))
+	}
+	dlog.Printf("no writable directory for the package index, using an in-memory index")
+	return index.InMemory
+}
+
+// hashedIndexName returns a file name for the index called name of projectRoot
+// which is unique among all projects that share a directory. A workspace and
+// the module at its root have different names, so they do not share an index.
+func hashedIndexName(projectRoot, name string) string {
+	sum := sha256.Sum256([]byte(projectRoot + string(filepath.Separator) + name))
+	return fmt.Sprintf("%s-%x.sqlite3", filepath.Base(projectRoot), sum[:8])
+}
+
+// ignoreIndexDir writes a .gitignore file into dir which ignores everything,
+// so that the index is not accidentally committed.
+func ignoreIndexDir(dir string) {
+	path := filepath.Join(dir, ".gitignore")
+	if _, err := os.Stat(path); !os.IsNotExist(err) {
+		return
+	}
+	if err := os.WriteFile(path, []byte("*\n"), 0644); err != nil {
+		dlog.Printf("failed to write %s: %v", path, err)
+	}
+}
+
+// userIndexCacheDir returns the go-doc directory in the user cache dir, or the
+// empty string if it is not writable.
+func userIndexCacheDir() string {
+	cacheDir, err := os.UserCacheDir()
+	if err != nil {
+		dlog.Printf("failed to locate user cache dir: %v", err)
+		return ""
+	}
+	dir := filepath.Join(cacheDir, "go-doc")
+	if err := index.WritableDir(dir); err != nil {
+		dlog.Printf("%v", err)
+		return ""
+	}
+	return dir
+}
+
+// sharedIndexCachePath returns the path to the index shared by all modules,
+// which holds the packages of the stdlib and any module@version, or the empty
+// string if it cannot be created.
+//
+// The shared index is stored in GODOC_INDEX_DIR, if set, and otherwise in the
+// user cache dir.
+func sharedIndexCachePath() string {
+	dir := globalIndexCacheDir()
+	if dir == "" {
+		return ""
+	}
+	return filepath.Join(dir, "shared.sqlite3")
+}
+
+// globalIndexCachePath returns the path to the index used outside of any
+// module, or in GOPATH mode, where the code roots are GOROOT/src and, in GOPATH
+// mode, the src directory of each GOPATH entry. It is stored with the shared
+// index, named for a hash of GOROOT and GOPATH. If no directory is writable,
+// index.InMemory is returned.
+func globalIndexCachePath() string {
+	dir := globalIndexCacheDir()
+	if dir == "" {
+		dlog.Printf("no writable directory for the package index, using an in-memory index")
+		return index.InMemory
+	}
+	name := "nomodule"
+	if !usingModules {
+		name = "gopath"
+	}
+	sum := sha256.Sum256([]byte(buildCtx.GOROOT + string(filepath.ListSeparator) + buildCtx.GOPATH))
+	return filepath.Join(dir, fmt.Sprintf("%s-%x.sqlite3", name, sum[:8]))
+}
+
+// globalIndexCacheDir returns GODOC_INDEX_DIR, if set, and otherwise the user
+// cache dir, or the empty string if it is not writable.
+func globalIndexCacheDir() string {
+	dir := os.Getenv(index.DirEnvVar)
+	if dir == "" {
+		return userIndexCacheDir()
+	}
+	if err := index.WritableDir(dir); err != nil {
+		dlog.Printf("%v", err)
+		return ""
+	}
+	return dir
 }
 func dirsToIndexModules(dirs ...Dir) []godoc.PackageDir {
 	mods := make([]godoc.PackageDir, len(dirs))
@@ -39,6 +285,9 @@ func dirsToIndexModules(dirs ...Dir) []godoc.PackageDir {
 	}
 	return mods
 }
+
+// moduleRootDir returns the root directory of the main module, or the empty
+// string outside of any module or in GOPATH mode.
 func moduleRootDir(goCmd string) string {
 	args := []string{"env", "GOMOD"}
 	stdout, err := exec.Command(goCmd, args...).Output()
@@ -46,5 +295,596 @@ func moduleRootDir(goCmd string) string {
 		dlog.Printf("failed to run `%s %s`: %v", goCmd, strings.Join(args, " "), err)
 		return ""
 	}
-	return filepath.Dir(string(bytes.TrimSpace(stdout)))
+	gomod := string(bytes.TrimSpace(stdout))
+	if gomod == "" || gomod == os.DevNull {
+		return ""
+	}
+	return filepath.Dir(gomod)
+}
+
+// goModCache returns the module cache directory, or the empty string if it
+// cannot be found.
+func goModCache() string {
+	args := []string{"env", "GOMODCACHE"}
+	stdout, err := exec.Command(goCmd(), args...).Output()
+	if err != nil {
+		dlog.Printf("failed to run `%s %s`: %v", goCmd(), strings.Join(args, " "), err)
+		return ""
+	}
+	return string(bytes.TrimSpace(stdout))
+}
+
+// notRequiredLabel returns a label for the package in dir if it belongs to
+// a module which is not required, otherwise the empty string.
+func notRequiredLabel(pkgIdx *index.Index, dir string) string {
+	if _, notRequired := pkgIdx.NotRequired(dir); !notRequired {
+		return ""
+	}
+	return " (not required)"
+}
+
+// printWhich prints every indexed package which exports a symbol matching
+// symbol, followed by the one-line summary of each matching symbol.
+func printWhich(w io.Writer, pkgIdx *index.Index, symbol string) error {
+	if pkgIdx == nil {
+		return fmt.Errorf("-which requires the package index")
+	}
+	matches, err := pkgIdx.Which(context.Background(), symbol)
+	if err != nil {
+		return err
+	}
+
+	if outfmt.IsRichMarkdown() {
+		fmt.Fprintln(w, outfmt.CodeBlockDelim+"go")
+		defer fmt.Fprintln(w, outfmt.CodeBlockDelim)
+	}
+	var found bool
+	var lastImportPath string
+	for _, m := range matches {
+		if !matchSymbol(symbol, m) {
+			continue
+		}
+		if !found || m.ImportPath != lastImportPath {
+			if found {
+				fmt.Fprintln(w)
+			}
+			fmt.Fprintf(w, "%s%s\n", m.ImportPath, notRequiredLabel(pkgIdx, m.Dir))
+			lastImportPath = m.ImportPath
+		}
+		found = true
+		summary := m.Summary
+		if m.Type != "" && !strings.HasPrefix(summary, "func ") {
+			// Struct fields and interface methods do not identify
+			// their type.
+			summary = m.Type + "." + summary
+		}
+		fmt.Fprintf(w, "%s%s\n", indent, summary)
+	}
+	if !found {
+		return fmt.Errorf("no indexed package exports %s", symbol)
+	}
+	return nil
+}
+
+// matchSymbol reports whether the user's <sym> or <type>.<method|field>
+// matches the indexed symbol according to the usual go doc rules.
+func matchSymbol(user string, sym index.SymbolMatch) bool {
+	typ, name, found := strings.Cut(user, ".")
+	if !found {
+		return match(user, sym.Name)
+	}
+	return match(typ, sym.Type) && match(name, sym.Name)
+}
+
+// searchLimit is the maximum number of results shown by -search.
+const searchLimit = 20
+
+// printSearch prints the packages and symbols whose docs best match the
+// query, along with a snippet of each doc with the matching words
+// highlighted.
+func printSearch(w io.Writer, pkgIdx *index.Index, query string) error {
+	if pkgIdx == nil {
+		return fmt.Errorf("-search requires the package index")
+	}
+	open, close := "*", "*"
+	if outfmt.IsRichMarkdown() {
+		open, close = "**", "**"
+	}
+	matches, err := pkgIdx.SearchDocs(context.Background(), query,
+		index.WithHighlight(open, close),
+		index.WithLimit(searchLimit),
+	)
+	if err != nil {
+		return err
+	}
+	if len(matches) == 0 {
+		return fmt.Errorf("no indexed docs match %q", query)
+	}
+
+	for i, m := range matches {
+		if i > 0 {
+			fmt.Fprintln(w)
+		}
+		title := m.ImportPath
+		if !m.IsPackage() {
+			title += "." + m.Name
+		}
+		title += notRequiredLabel(pkgIdx, m.Dir)
+		// Doc comments are wrapped, so join their lines.
+		snippet := strings.Join(strings.Fields(m.Snippet), " ")
+
+		if !outfmt.IsRichMarkdown() {
+			fmt.Fprintln(w, title)
+			if m.Summary != "" {
+				fmt.Fprintf(w, "%s%s\n", indent, m.Summary)
+			}
+			if snippet != "" {
+				fmt.Fprintf(w, "%s%s\n", indent, snippet)
+			}
+			continue
+		}
+
+		fmt.Fprintf(w, "### %s\n\n", title)
+		if m.Summary != "" {
+			fmt.Fprintf(w, "%sgo\n%s\n%s\n\n", outfmt.CodeBlockDelim, m.Summary, outfmt.CodeBlockDelim)
+		}
+		if snippet != "" {
+			fmt.Fprintln(w, snippet)
+		}
+	}
+	return nil
+}
+
+// runIndexCommands runs the index maintenance commands in the order: sync,
+// rebuild, vacuum, path, stats.
+func runIndexCommands(w io.Writer, pkgIdx *index.Index) error {
+	if pkgIdx == nil {
+		return fmt.Errorf("the package index is not available")
+	}
+	ctx := context.Background()
+	if index.SyncOnly {
+		if err := pkgIdx.WaitSync(); err != nil {
+			return err
+		}
+	}
+	if index.Rebuild {
+		if err := pkgIdx.Rebuild(ctx); err != nil {
+			return err
+		}
+	}
+	if index.Vacuum {
+		if err := pkgIdx.Vacuum(ctx); err != nil {
+			return err
+		}
+	}
+	if index.ShowPath {
+		fmt.Fprintln(w, pkgIdx.Path())
+	}
+	if index.ShowStats {
+		stats, err := pkgIdx.Stats(ctx)
+		if err != nil {
+			return err
+		}
+		printIndexStats(w, stats)
+	}
+	return nil
+}
+
+func printIndexStats(w io.Writer, stats index.Stats) {
+	formatTime := func(t time.Time) string {
+		if t.IsZero() {
+			return "never"
+		}
+		return t.Local().Format(time.DateTime)
+	}
+	tw := tabwriter.NewWriter(w, 0, 4, 2, ' ', 0)
+	fmt.Fprintf(tw, "path:\t%s\n", stats.Path)
+	fmt.Fprintf(tw, "created at:\t%s\n", formatTime(stats.CreatedAt))
+	fmt.Fprintf(tw, "updated at:\t%s\n", formatTime(stats.UpdatedAt))
+	fmt.Fprintf(tw, "build revision:\t%s\n", stats.BuildRevision)
+	fmt.Fprintf(tw, "go version:\t%s\n", stats.GoVersion)
+	fmt.Fprintf(tw, "resync due:\t%t\n", stats.ResyncDue)
+	tw.Flush()
+
+	fmt.Fprintln(w)
+	tw = tabwriter.NewWriter(w, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(tw, "MODULE\tVERSION\tCLASS\tVENDOR\tSHARED\tPACKAGES\tSYNCED AT")
+	for _, mod := range stats.Modules {
+		importPath := mod.ImportPath
+		switch {
+		case importPath != "":
+		case mod.Class == "local":
+			importPath = "GOPATH"
+		default:
+			importPath = "std"
+		}
+		fmt.Fprintf(tw, "%s\t%s\t%s\t%t\t%t\t%d\t%s\n",
+			importPath, mod.Version, mod.Class, mod.Vendor, mod.Shared,
+			mod.NumPackages, formatTime(mod.SyncedAt))
+	}
+	tw.Flush()
+
+	if stats.Shared != nil {
+		fmt.Fprintln(w)
+		fmt.Fprintln(w, "shared index:")
+		printIndexStats(w, *stats.Shared)
+	}
+}
+
+// served is the state kept between the requests handled by a daemon.
+var served struct {
+	serving bool
+	pkgIdx  *index.Index
+	// modCache is the value of index.ModCache when pkgIdx was loaded.
+	modCache bool
+	// packages are the packages parsed for completions, keyed by their
+	// directory. See cachedPackage.
+	packages map[string]parsedPackage
+}
+
+// serve handles requests for the current module or workspace until idle. See
+// internal/daemon.
+func serve() error {
+	wd, err := os.Getwd()
+	if err != nil {
+		return err
+	}
+	root := daemon.ProjectRoot(wd)
+	if root == "" {
+		return fmt.Errorf("-serve requires a module or workspace")
+	}
+	ln, err := daemon.Listen(daemon.SocketPath(root))
+	if errors.Is(err, daemon.ErrRunning) {
+		dlog.Printf("daemon for %q is already running", root)
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("failed to listen: %w", err)
+	}
+
+	served.serving = true
+	defer func() {
+		served.serving = false
+		if served.pkgIdx != nil {
+			served.pkgIdx.Close()
+		}
+	}()
+	return daemon.Serve(context.Background(), ln, daemon.IdleTimeout, func(w io.Writer, req daemon.Request) error {
+		if err := os.Chdir(req.Dir); err != nil {
+			return err
+		}
+		return do(w, flag.NewFlagSet(os.Args[0], flag.ContinueOnError), req.Args)
+	})
+}
+
+// sendToDaemon sends the request given by args to the daemon serving the
+// current module or workspace, and writes its output to w. It reports whether
+// the request was handled, otherwise the caller must handle it.
+//
+// Completions are always sent, while other requests are only sent if stdout is
+// not a terminal, since docs are paged and formatted for the terminal of the
+// client.
+func sendToDaemon(w io.Writer, args []string) bool {
+	mode := daemon.Mode()
+	if mode == "off" || !sendableArgs(args) {
+		return false
+	}
+	if (len(args) == 0 || args[0] != "-complete") && pager.IsTTY(w) {
+		return false
+	}
+
+	req, err := daemon.NewRequest(args)
+	if err != nil {
+		return false
+	}
+	root := daemon.ProjectRoot(req.Dir)
+	if root == "" {
+		return false
+	}
+	res, err := daemon.Send(daemon.SocketPath(root), req)
+	if err != nil {
+		if mode == "auto" {
+			startDaemon(root)
+		}
+		return false
+	}
+	w.Write(res.Output)
+	if res.Err != "" {
+		log.Fatal(res.Err)
+	}
+	return true
+}
+
+// sendableArgs reports whether args may be handled by a daemon. Flags which
+// interact with the user, change the working directory, or maintain the
+// index must be handled by the client.
+func sendableArgs(args []string) bool {
+	for _, arg := range args {
+		if !strings.HasPrefix(arg, "-") {
+			continue
+		}
+		name, val, _ := strings.Cut(strings.TrimLeft(arg, "-"), "=")
+		if val == "false" {
+			continue
+		}
+		switch name {
+		case "C", "h", "help", "open", "install-completion", "serve",
+			"index-stats", "index-path", "index-rebuild", "index-vacuum", "index-sync":
+			return false
+		}
+	}
+	return true
+}
+
+// startDaemon starts a daemon for the project rooted at root in the
+// background.
+func startDaemon(root string) {
+	exe, err := os.Executable()
+	if err != nil {
+		dlog.Printf("failed to start daemon: %v", err)
+		return
+	}
+	cmd := exec.Command(exe, "-serve")
+	cmd.Dir = root
+	if err := cmd.Start(); err != nil {
+		dlog.Printf("failed to start daemon: %v", err)
+		return
+	}
+	cmd.Process.Release()
+}
+
+// maxSuggestions is the number of "did you mean" suggestions shown.
+const maxSuggestions = 5
+
+// suggestOnError adds "did you mean" suggestions to *errp when no package or
+// symbol matched the args of fs. With -autocorrect, the docs of the best
+// suggestion are written to w instead.
+//
+// It must be deferred by do, as it also recovers any PackageError, and the
+// errors of fatalf while serving, which are not suggested for.
+func suggestOnError(errp *error, w io.Writer, fs *flag.FlagSet, pkgIdx *index.Index) {
+	switch e := recover().(type) {
+	case nil:
+	case PackageError:
+		*errp = e
+	case fatalError:
+		*errp = e.error
+		return
+	default:
+		panic(e)
+	}
+	if *errp == nil || completion.Requested ||
+		index.Which != "" || index.SearchQuery != "" || index.IsCommand() {
+		return
+	}
+
+	suggestions := suggestDocs(pkgIdx, fs.Args())
+	switch {
+	case len(suggestions) == 0:
+	case suggest.Autocorrect:
+		log.Printf("%v; showing %s", *errp, suggestions[0])
+		*errp = do(w, flag.NewFlagSet(fs.Name(), fs.ErrorHandling()), autocorrectArgs(fs, suggestions[0]))
+	case len(suggestions) == 1:
+		*errp = fmt.Errorf("%w\ndid you mean %s?", *errp, suggestions[0])
+	default:
+		*errp = fmt.Errorf("%w\ndid you mean one of these?\n\t%s", *errp, strings.Join(suggestions, "\n\t"))
+	}
+}
+
+// autocorrectArgs returns the args to run go doc for the suggestion with the
+// same flags as fs.
+func autocorrectArgs(fs *flag.FlagSet, suggestion string) []string {
+	var args []string
+	fs.Visit(func(f *flag.Flag) {
+		switch f.Name {
+		case "C": // Already changed dir.
+			return
+		}
+		args = append(args, "-"+f.Name+"="+f.Value.String())
+	})
+	return append(args, suggestion)
+}
+
+// suggestDocs returns the arguments for go doc which args may have been
+// a typo of, closest first.
+//
+// Every way of splitting args into a package and symbol is considered, and
+// the symbols of the closest matching packages are compared.
+func suggestDocs(pkgIdx *index.Index, args []string) []string {
+	type spec struct{ pkgPath, symbol string }
+	var specs []spec
+	switch len(args) {
+	case 1:
+		arg := args[0]
+		// <sym>[.<methodOrField>] in the current directory.
+		specs = append(specs, spec{"", arg})
+		// <pkg>[.<sym>[.<methodOrField>]]
+		for start := strings.LastIndex(arg, "/") + 1; ; {
+			period := strings.Index(arg[start:], ".")
+			if period < 0 {
+				specs = append(specs, spec{arg, ""})
+				break
+			}
+			period += start
+			specs = append(specs, spec{arg[:period], arg[period+1:]})
+			start = period + 1
+		}
+	case 2:
+		specs = append(specs, spec{args[0], args[1]})
+	}
+
+	// Packages are suggested in the order of the index, so the order in
+	// which each arg is first suggested breaks ties.
+	var matches []suggest.Match[string]
+	seen := make(map[string]int)
+	add := func(arg string, dist int) {
+		if i, ok := seen[arg]; ok {
+			matches[i].Distance = min(matches[i].Distance, dist)
+			return
+		}
+		seen[arg] = len(matches)
+		matches = append(matches, suggest.Match[string]{Value: arg, Distance: dist})
+	}
+	for _, spec := range specs {
+		for _, pkg := range suggestPackages(pkgIdx, spec.pkgPath) {
+			if spec.symbol == "" {
+				add(pkg.Value.ImportPath, pkg.Distance)
+				continue
+			}
+			for _, sym := range suggestSymbols(pkg.Value, spec.symbol) {
+				arg := sym.Value
+				if spec.pkgPath != "" {
+					arg = pkg.Value.ImportPath + "." + arg
+				}
+				add(arg, pkg.Distance+sym.Distance)
+			}
+		}
+	}
+	suggest.Sort(matches)
+
+	var suggestions []string
+	for _, m := range matches {
+		if m.Distance == 0 { // This is what was asked for.
+			continue
+		}
+		suggestions = append(suggestions, m.Value)
+		if len(suggestions) == maxSuggestions {
+			break
+		}
+	}
+	return suggestions
+}
+
+// suggestPackages returns the packages which path may be a typo of, closest
+// first, or the package in the current directory if path is empty.
+func suggestPackages(pkgIdx *index.Index, path string) []suggest.Match[godoc.PackageDir] {
+	if path == "" {
+		wd, err := os.Getwd()
+		if err != nil {
+			return nil
+		}
+		pkg, err := build.ImportDir(wd, build.ImportComment)
+		if err != nil {
+			return nil
+		}
+		return []suggest.Match[godoc.PackageDir]{{Value: godoc.NewPackageDir(pkg.ImportPath, pkg.Dir)}}
+	}
+	if isDotSlash(path) || filepath.IsAbs(path) {
+		return nil
+	}
+
+	var matches []suggest.Match[godoc.PackageDir]
+	if pkgIdx != nil {
+		var err error
+		matches, err = pkgIdx.Suggest(context.Background(), path)
+		if err != nil {
+			dlog.Printf("failed to suggest packages: %v", err)
+		}
+	} else {
+		maxDist := suggest.MaxDistance(path)
+		dirs.Reset()
+		for {
+			d, ok := dirs.Next()
+			if !ok {
+				break
+			}
+			dist := suggest.PathDistance(path, d.importPath)
+			if dist < 0 || dist > maxDist {
+				continue
+			}
+			matches = append(matches, suggest.Match[godoc.PackageDir]{
+				Value:    godoc.NewPackageDir(d.importPath, d.dir),
+				Distance: dist,
+			})
+		}
+		suggest.Sort(matches)
+	}
+	return matches[:min(len(matches), maxSuggestions)]
+}
+
+// suggestSymbols returns the exported symbols of pkg which symbol, of the form
+// <sym>[.<methodOrField>], may be a typo of, closest first.
+func suggestSymbols(pkgDir godoc.PackageDir, symbol string) []suggest.Match[string] {
+	sym, method, hasMethod := strings.Cut(symbol, ".")
+	if !suggest.IsIdentifier(sym) || (hasMethod && !suggest.IsIdentifier(method)) {
+		return nil
+	}
+	buildPkg, err := build.ImportDir(pkgDir.Dir, build.ImportComment)
+	if err != nil {
+		return nil
+	}
+	pkg, err := parseExportedPackage(buildPkg)
+	if err != nil {
+		return nil
+	}
+
+	var matches []suggest.Match[string]
+	add := func(typ, name string) {
+		if !isExported(name) {
+			return
+		}
+		if (typ != "") != hasMethod {
+			return
+		}
+		dist := suggest.Distance(sym, name)
+		if hasMethod {
+			dist = suggest.Distance(sym, typ)
+			if dist > suggest.MaxDistance(sym) {
+				return
+			}
+			dist += suggest.Distance(method, name)
+			name = typ + "." + name
+		}
+		if dist > suggest.MaxDistance(symbol) {
+			return
+		}
+		matches = append(matches, suggest.Match[string]{Value: name, Distance: dist})
+	}
+	addValues := func(values []*doc.Value) {
+		for _, value := range values {
+			for _, name := range value.Names {
+				add("", name)
+			}
+		}
+	}
+	addFuncs := func(typ string, funcs []*doc.Func) {
+		for _, fnc := range funcs {
+			add(typ, fnc.Name)
+		}
+	}
+
+	docPkg := pkg.Doc()
+	addValues(docPkg.Consts)
+	addValues(docPkg.Vars)
+	addFuncs("", docPkg.Funcs)
+	for _, typ := range docPkg.Types {
+		add("", typ.Name)
+		addValues(typ.Consts)
+		addValues(typ.Vars)
+		addFuncs("", typ.Funcs)
+		addFuncs(typ.Name, typ.Methods)
+		for _, spec := range typ.Decl.Specs {
+			typeSpec, ok := spec.(*ast.TypeSpec)
+			if !ok || typeSpec.Name.Name != typ.Name {
+				continue
+			}
+			var fields *ast.FieldList
+			switch t := typeSpec.Type.(type) {
+			case *ast.StructType:
+				fields = t.Fields
+			case *ast.InterfaceType:
+				fields = t.Methods
+			}
+			if fields == nil {
+				continue
+			}
+			for _, field := range fields.List {
+				for _, name := range field.Names {
+					add(typ.Name, name.Name)
+				}
+			}
+		}
+	}
+	suggest.Sort(matches)
+	return matches
 }
@@ -0,0 +1,122 @@
+package main
+
+import (
+	"bytes"
+	"flag"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"aslevy.com/go-doc/internal/index"
+)
+
+func TestIndexCachePath(t *testing.T) {
+	projectRoot := t.TempDir()
+	t.Setenv(index.DirEnvVar, "")
+
+	// The index is stored in the .go-doc directory of the project, which
+	// ignores itself.
+	path := indexCachePath(projectRoot, "packages.sqlite3")
+	if want := filepath.Join(projectRoot, ".go-doc", "packages.sqlite3"); path != want {
+		t.Errorf("indexCachePath() = %q, want %q", path, want)
+	}
+	if _, err := os.Stat(filepath.Join(projectRoot, ".go-doc", ".gitignore")); err != nil {
+		t.Errorf("the index dir is not ignored: %v", err)
+	}
+
+	// GODOC_INDEX_DIR holds the indexes of every project, named for a hash
+	// of their root and name.
+	indexDir := filepath.Join(t.TempDir(), "indexes")
+	t.Setenv(index.DirEnvVar, indexDir)
+	modPath := indexCachePath(projectRoot, "packages.sqlite3")
+	workPath := indexCachePath(projectRoot, "go.work.sqlite3")
+	otherPath := indexCachePath(t.TempDir(), "packages.sqlite3")
+	for _, path := range []string{modPath, workPath, otherPath} {
+		if filepath.Dir(path) != indexDir {
+			t.Errorf("indexCachePath() = %q, want a file in %q", path, indexDir)
+		}
+	}
+	if modPath == workPath {
+		t.Errorf("the module and workspace at %q share the index %q", projectRoot, modPath)
+	}
+	if modPath == otherPath {
+		t.Errorf("two projects share the index %q", modPath)
+	}
+	if again := indexCachePath(projectRoot, "packages.sqlite3"); again != modPath {
+		t.Errorf("indexCachePath() = %q, then %q", modPath, again)
+	}
+
+	// An unusable GODOC_INDEX_DIR falls back to an in-memory index.
+	notDir := filepath.Join(t.TempDir(), "file")
+	if err := os.WriteFile(notDir, nil, 0644); err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv(index.DirEnvVar, notDir)
+	if path := indexCachePath(projectRoot, "packages.sqlite3"); path != index.InMemory {
+		t.Errorf("indexCachePath() = %q, want %q", path, index.InMemory)
+	}
+
+	// A project whose .go-doc directory cannot be created falls back to the
+	// user cache dir.
+	t.Setenv(index.DirEnvVar, "")
+	cacheDir := t.TempDir()
+	t.Setenv("XDG_CACHE_HOME", cacheDir)
+	t.Setenv("HOME", cacheDir)
+	readOnlyRoot := t.TempDir()
+	if err := os.WriteFile(filepath.Join(readOnlyRoot, ".go-doc"), nil, 0644); err != nil {
+		t.Fatal(err)
+	}
+	userCacheDir, err := os.UserCacheDir()
+	if err != nil {
+		t.Skip(err)
+	}
+	path = indexCachePath(readOnlyRoot, "packages.sqlite3")
+	if want := filepath.Join(userCacheDir, "go-doc"); filepath.Dir(path) != want {
+		t.Errorf("indexCachePath() = %q, want a file in %q", path, want)
+	}
+}
+
+func TestSuggestOnError(t *testing.T) {
+	for _, test := range []struct {
+		name string
+		arg  string
+		want string
+	}{
+		{"package", "testdatta", "did you mean testdata?"},
+		{"symbol", "testdata.ExportedFnuc", "did you mean one of these?\n\ttestdata.ExportedFunc\n"},
+	} {
+		t.Run(test.name, func(t *testing.T) {
+			var out bytes.Buffer
+			err := do(&out, flag.NewFlagSet("go doc", flag.ContinueOnError), []string{test.arg})
+			// The suggestions are added to the error, so go doc still fails.
+			if err == nil {
+				t.Fatalf("do(%q) succeeded, want an error", test.arg)
+			}
+			if !strings.Contains(err.Error(), test.want) {
+				t.Errorf("do(%q) = %v, want %q", test.arg, err, test.want)
+			}
+		})
+	}
+}
+
+func TestSendableArgs(t *testing.T) {
+	for _, test := range []struct {
+		args []string
+		want bool
+	}{
+		{[]string{"fmt.Println"}, true},
+		{[]string{"-u", "-src", "fmt"}, true},
+		{[]string{"-complete", "fmt.Pr"}, true},
+		{[]string{"-C", "dir", "fmt"}, false},
+		{[]string{"-open", "fmt"}, false},
+		{[]string{"-index-stats"}, false},
+		{[]string{"-index-sync"}, false},
+		{[]string{"--index-sync=true"}, false},
+		{[]string{"-index-sync=false", "fmt"}, true},
+	} {
+		if got := sendableArgs(test.args); got != test.want {
+			t.Errorf("sendableArgs(%q) = %v, want %v", test.args, got, test.want)
+		}
+	}
+}
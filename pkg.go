@@ -152,6 +152,9 @@ func parsePackage(writer io.Writer, pkg *build.Package, userPath string) *Packag
 	if completion.Requested && pkg == nil {
 		return nil
 	}
+	if p := cachedPackage(writer, pkg, userPath); p != nil {
+		return p
+	}
 	// include tells parser.ParseDir which files to include.
 	// That means the file must be in the build package's GoFiles or CgoFiles
 	// list only (no tag-ignored files, tests, swig or other non-Go files).
@@ -174,20 +177,20 @@ func parsePackage(writer io.Writer, pkg *build.Package, userPath string) *Packag
 		if completion.Requested {
 			return nil
 		}
-		log.Fatal(err)
+		fatal(err)
 	}
 	// Make sure they are all in one package.
 	if len(pkgs) == 0 {
 		if completion.Requested {
 			return nil
 		}
-		log.Fatalf("no source-code package in directory %s", pkg.Dir)
+		fatalf("no source-code package in directory %s", pkg.Dir)
 	}
 	if len(pkgs) > 1 {
 		if completion.Requested {
 			return nil
 		}
-		log.Fatalf("multiple packages in directory %s", pkg.Dir)
+		fatalf("multiple packages in directory %s", pkg.Dir)
 	}
 	astPkg := pkgs[pkg.Name]
 
@@ -241,6 +244,7 @@ func parsePackage(writer io.Writer, pkg *build.Package, userPath string) *Packag
 	if !godoc.NoImports {
 		p.pkgRefs = make(astutil.PackageReferences)
 	}
+	cachePackage(p)
 	return p
 }
 
@@ -252,7 +256,7 @@ func (pkg *Package) flush() {
 	pkg.flushImports()
 	_, err := pkg.writer.Write(pkg.buf.Bytes())
 	if err != nil {
-		log.Fatal(err)
+		fatal(err)
 	}
 	pkg.buf.Reset() // Not needed, but it's a flush.
 }
@@ -286,7 +290,7 @@ func (pkg *Package) emit(comment string, node ast.Node) {
 		pkg.buf.Code()
 		err := format.Node(&pkg.buf, pkg.fs, arg)
 		if err != nil {
-			log.Fatal(err)
+			fatal(err)
 		}
 		pkg.emitLocation(node)
 		if comment != "" && !showSrc {
@@ -675,11 +679,14 @@ func (pkg *Package) packageClause() {
 				break
 			}
 		}
+		importPath = notRequiredImportPath(pkg.build.Dir, importPath)
 	}
 
 	pkg.buf.Code()
-	pkg.Printf("package %s // import \"%s\"\n\n", pkg.name, importPathLink(importPath))
+	pkg.moduleHeader()
+	pkg.Printf("package %s // import \"%s\"%s\n\n", pkg.name, importPathLink(importPath), moduleVersionSuffix(pkg.build.Dir))
 	pkg.endOfPkgClause = pkg.buf.Len()
+	pkg.notRequiredWarning()
 	if !usingModules && importPath != pkg.build.ImportPath {
 		pkg.buf.Text()
 		pkg.Printf("WARNING: package source is installed in %q\n", pkg.build.ImportPath)
@@ -941,6 +948,7 @@ func (pkg *Package) typeDoc(typ *doc.Type) {
 		pkg.funcSummary(typ.Funcs, true)
 		pkg.funcSummary(typ.Methods, true)
 	}
+	pkg.implementsDoc(typ)
 }
 
 // trimUnexportedElems modifies spec in place to elide unexported fields from
@@ -1134,7 +1142,7 @@ func (pkg *Package) printMethodDoc(symbol, method string) bool {
 			inter.Methods.List, methods = methods, inter.Methods.List
 			err := format.Node(&pkg.buf, pkg.fs, inter)
 			if err != nil {
-				log.Fatal(err)
+				fatal(err)
 			}
 			pkg.newlines(1)
 			// Restore the original methods.
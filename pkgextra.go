@@ -1,14 +1,27 @@
 package main
 
 import (
+	"context"
+	"fmt"
 	"go/ast"
+	"go/build"
 	"go/doc"
-	"log"
+	"go/parser"
+	"go/token"
+	"go/types"
+	"io"
+	"io/fs"
+	"os"
 	"path"
+	"path/filepath"
+	"strings"
 
 	"aslevy.com/go-doc/internal/astutil"
+	"aslevy.com/go-doc/internal/completion"
 	"aslevy.com/go-doc/internal/godoc"
+	"aslevy.com/go-doc/internal/implements"
 	"aslevy.com/go-doc/internal/outfmt"
+	"aslevy.com/go-doc/internal/refs"
 	"aslevy.com/go-doc/internal/workdir"
 	"github.com/muesli/termenv"
 )
@@ -70,7 +83,7 @@ func (pkg *Package) flushImports() {
 	// import block.
 	_, err := pkg.writer.Write(pkg.buf.Next(pkg.endOfPkgClause))
 	if err != nil {
-		log.Fatal(err)
+		fatal(err)
 	}
 	if godoc.NoImports {
 		return
@@ -79,7 +92,7 @@ func (pkg *Package) flushImports() {
 	if err := astutil.NewPackageResolver(pkg.fs, pkg.pkg).
 		BuildImports(pkg.pkgRefs, godoc.ShowStdlib).
 		Render(pkg.writer); err != nil {
-		log.Fatal(err)
+		fatal(err)
 	}
 }
 
@@ -160,3 +173,511 @@ func (pkg *Package) oneLineFieldList(list *ast.FieldList, depth int, opts ...god
 	}
 	return params, needParens
 }
+
+// loadSymbols returns the package doc and exported symbols declared in the
+// package in pkgDir along with their one-line summaries and docs. It is used to
+// populate the package index, so errors are returned instead of being fatal.
+func loadSymbols(_ context.Context, pkgDir godoc.PackageDir) (godoc.PackageSymbols, error) {
+	buildPkg, err := build.ImportDir(pkgDir.Dir, build.ImportComment)
+	if err != nil {
+		return godoc.PackageSymbols{}, err
+	}
+	pkg, err := parseExportedPackage(buildPkg)
+	if err != nil {
+		return godoc.PackageSymbols{}, err
+	}
+
+	pkgSyms := godoc.PackageSymbols{Name: pkg.name, Doc: pkg.doc.Doc}
+	add := func(kind godoc.SymbolKind, typ, name, summary, doc string) {
+		if !token.IsExported(name) {
+			return
+		}
+		pkgSyms.Symbols = append(pkgSyms.Symbols, godoc.Symbol{
+			Kind:    kind,
+			Type:    typ,
+			Name:    name,
+			Summary: summary,
+			Doc:     doc,
+		})
+	}
+	addValues := func(kind godoc.SymbolKind, values []*doc.Value) {
+		for _, value := range values {
+			for _, name := range value.Names {
+				add(kind, "", name, pkg.oneLineNode(value.Decl, godoc.WithValueName(name)), value.Doc)
+			}
+		}
+	}
+	addFuncs := func(kind godoc.SymbolKind, typ string, funcs []*doc.Func) {
+		for _, fun := range funcs {
+			add(kind, typ, fun.Name, pkg.oneLineNode(fun.Decl), fun.Doc)
+		}
+	}
+
+	addValues(godoc.SymbolConst, pkg.doc.Consts)
+	addValues(godoc.SymbolVar, pkg.doc.Vars)
+	addFuncs(godoc.SymbolFunc, "", pkg.doc.Funcs)
+	for _, typ := range pkg.doc.Types {
+		spec := pkg.findTypeSpec(typ.Decl, typ.Name)
+		add(godoc.SymbolType, "", typ.Name, pkg.oneLineNode(spec), typ.Doc)
+		addValues(godoc.SymbolConst, typ.Consts)
+		addValues(godoc.SymbolVar, typ.Vars)
+		addFuncs(godoc.SymbolFunc, "", typ.Funcs)
+		addFuncs(godoc.SymbolMethod, typ.Name, typ.Methods)
+
+		switch typSpec := spec.Type.(type) {
+		case *ast.StructType:
+			for _, field := range typSpec.Fields.List {
+				fieldType := pkg.oneLineNode(field.Type)
+				for _, name := range field.Names {
+					add(godoc.SymbolField, typ.Name, name.Name, name.Name+" "+fieldType, field.Doc.Text())
+				}
+			}
+		case *ast.InterfaceType:
+			for _, method := range typSpec.Methods.List {
+				// Embedded interfaces have no names.
+				if len(method.Names) == 0 {
+					continue
+				}
+				name := method.Names[0].Name
+				add(godoc.SymbolMethod, typ.Name, name, name+strings.TrimPrefix(pkg.oneLineNode(method.Type), "func"), method.Doc.Text())
+			}
+		}
+	}
+	return pkgSyms, nil
+}
+
+// parseExportedPackage is like parsePackage but only retains exported
+// declarations, does not write any output, and returns any errors instead of
+// exiting. Comments are parsed so that docs are available.
+func parseExportedPackage(buildPkg *build.Package) (*Package, error) {
+	include := func(info fs.FileInfo) bool {
+		for _, name := range buildPkg.GoFiles {
+			if name == info.Name() {
+				return true
+			}
+		}
+		for _, name := range buildPkg.CgoFiles {
+			if name == info.Name() {
+				return true
+			}
+		}
+		return false
+	}
+	fset := token.NewFileSet()
+	pkgs, err := parser.ParseDir(fset, buildPkg.Dir, include, parser.ParseComments)
+	if err != nil {
+		return nil, err
+	}
+	astPkg, ok := pkgs[buildPkg.Name]
+	if !ok {
+		return nil, fmt.Errorf("no source-code package in directory %s", buildPkg.Dir)
+	}
+
+	pkg := &Package{
+		writer: io.Discard,
+		name:   buildPkg.Name,
+		pkg:    astPkg,
+		doc:    doc.New(astPkg, buildPkg.ImportPath, 0),
+		build:  buildPkg,
+		fs:     fset,
+	}
+	pkg.buf.pkg = pkg
+	return pkg, nil
+}
+
+// maxParsedPackages bounds the number of packages kept by a daemon. See
+// cachedPackage.
+const maxParsedPackages = 256
+
+// parsedPackage is a package parsed for completions by a daemon.
+type parsedPackage struct {
+	pkg   *Package
+	mode  doc.Mode
+	files string
+}
+
+// cachedPackage returns the package in the directory of buildPkg as it was last
+// parsed by parsePackage, or nil if it has not been, or any of its files have
+// changed since.
+//
+// Packages are only cached for completions while serving. Rendering docs edits
+// the AST of a package, e.g. to trim unexported fields, so a package is never
+// reused for docs.
+func cachedPackage(writer io.Writer, buildPkg *build.Package, userPath string) *Package {
+	if !served.serving || !completion.Requested || buildPkg == nil {
+		return nil
+	}
+	parsed, ok := served.packages[buildPkg.Dir]
+	if !ok || parsed.pkg.build.ImportPath != buildPkg.ImportPath ||
+		parsed.mode != parseMode() || parsed.files != packageFiles(buildPkg) {
+		return nil
+	}
+	p := &Package{
+		writer:      writer,
+		name:        parsed.pkg.name,
+		userPath:    userPath,
+		pkg:         parsed.pkg.pkg,
+		file:        parsed.pkg.file,
+		doc:         parsed.pkg.doc,
+		typedValue:  parsed.pkg.typedValue,
+		constructor: parsed.pkg.constructor,
+		build:       buildPkg,
+		fs:          parsed.pkg.fs,
+	}
+	p.buf.pkg = p
+	if !godoc.NoImports {
+		p.pkgRefs = make(astutil.PackageReferences)
+	}
+	return p
+}
+
+// cachePackage records pkg, which was just parsed by parsePackage, for
+// cachedPackage.
+func cachePackage(pkg *Package) {
+	if !served.serving || !completion.Requested {
+		return
+	}
+	if served.packages == nil || len(served.packages) >= maxParsedPackages {
+		served.packages = make(map[string]parsedPackage)
+	}
+	served.packages[pkg.build.Dir] = parsedPackage{
+		pkg:   pkg,
+		mode:  parseMode(),
+		files: packageFiles(pkg.build),
+	}
+}
+
+// parseMode returns the mode with which parsePackage builds the docs of a
+// package.
+func parseMode() doc.Mode {
+	mode := doc.AllDecls
+	if showSrc || outfmt.PreserveAST() {
+		mode |= doc.PreserveAST
+	}
+	return mode
+}
+
+// packageFiles returns the names, sizes and modification times of the files of
+// buildPkg, which change if any file is edited, added or removed.
+func packageFiles(buildPkg *build.Package) string {
+	var b strings.Builder
+	for _, name := range append(buildPkg.GoFiles[:len(buildPkg.GoFiles):len(buildPkg.GoFiles)], buildPkg.CgoFiles...) {
+		info, err := os.Stat(filepath.Join(buildPkg.Dir, name))
+		if err != nil {
+			return ""
+		}
+		fmt.Fprintf(&b, "%s %d %d\n", name, info.Size(), info.ModTime().UnixNano())
+	}
+	return b.String()
+}
+
+// notRequiredImportPath returns the import path of the package in dir if it
+// belongs to a module in the module cache which is not required, since such
+// modules are not code roots. Otherwise importPath is returned.
+func notRequiredImportPath(dir, importPath string) string {
+	dirs, ok := xdirs.(godoc.NotRequiredDirs)
+	if !ok {
+		return importPath
+	}
+	if notRequiredPath, notRequired := dirs.NotRequired(dir); notRequired {
+		return notRequiredPath
+	}
+	return importPath
+}
+
+// moduleVersionSuffix returns the version of the module of the package in dir,
+// and its replacement, if any, to follow the import comment of the package
+// clause, so that it is clear which version of a dependency is documented.
+func moduleVersionSuffix(dir string) string {
+	dirs, ok := xdirs.(godoc.ModuleVersionDirs)
+	if !ok {
+		return ""
+	}
+	if version, ok := dirs.ModuleVersion(dir); ok {
+		return " (" + version + ")"
+	}
+	return ""
+}
+
+// moduleHeader prints the module of the package, its version or replacement,
+// and its class, as a comment above the package clause, if -module is set.
+//
+//	// module github.com/foo/bar/v2 v2.3.1 (required)
+func (pkg *Package) moduleHeader() {
+	if !godoc.ShowModule {
+		return
+	}
+	dirs, ok := xdirs.(godoc.ModuleDirs)
+	if !ok {
+		return
+	}
+	mod, ok := dirs.Module(pkg.build.Dir)
+	if !ok {
+		return
+	}
+	mod = goModRequirement(mod)
+	header := "// module " + mod.Path
+	if mod.Version != "" {
+		header += " " + mod.Version
+	}
+	if mod.Replace != "" {
+		header += " => " + mod.Replace
+	}
+	pkg.Printf("%s (%s)\n", header, mod.Class)
+}
+
+// goModRequirement returns mod with the version which is required by the
+// go.mod file of the main module, and its replacement, if any. The directory of
+// a replaced module only tells the version of its replacement, if any, but the
+// requirements of vendored modules are already known from vendor/modules.txt.
+//
+// The modules of a workspace are local, even if the main module requires them,
+// since go.work replaces their requirements.
+func goModRequirement(mod godoc.Module) godoc.Module {
+	if mod.Class == "stdlib" || mod.Class == "vendored" || workspaceModules()[mod.Path] {
+		return mod
+	}
+	f := mainGoMod()
+	if f == nil {
+		return mod
+	}
+	var required string
+	for _, req := range f.Require {
+		if req.Mod.Path == mod.Path {
+			required = req.Mod.Version
+		}
+	}
+	if required == "" {
+		// The main module, or a module which is not required.
+		return mod
+	}
+	for _, rep := range f.Replace {
+		if rep.Old.Path != mod.Path ||
+			(rep.Old.Version != "" && rep.Old.Version != required) {
+			continue
+		}
+		mod.Replace = rep.New.Path
+		if rep.New.Version != "" {
+			mod.Replace += " " + rep.New.Version
+		}
+	}
+	mod.Version = required
+	if mod.Class == "local" {
+		// The module is replaced by a directory.
+		mod.Class = "required"
+	}
+	return mod
+}
+
+// notRequiredWarning warns that the package belongs to a module which is not
+// required, and so cannot be imported without go get.
+func (pkg *Package) notRequiredWarning() {
+	dirs, ok := xdirs.(godoc.NotRequiredDirs)
+	if !ok {
+		return
+	}
+	if _, notRequired := dirs.NotRequired(pkg.build.Dir); notRequired {
+		pkg.buf.Text()
+		pkg.Printf("WARNING: package is in a module which is not required by the main module\n")
+	}
+}
+
+// refSymbol is a symbol or method whose references are listed by printRefs.
+type refSymbol struct {
+	decl           string // one-line summary of the declaration
+	symbol, method string
+}
+
+// findRefSymbols returns the symbols matching symbol, or the methods matching
+// symbol.method, along with the one-line summary of each.
+func (pkg *Package) findRefSymbols(symbol, method string) []refSymbol {
+	var syms []refSymbol
+	if method != "" {
+		for _, typ := range pkg.findTypes(symbol) {
+			for _, meth := range typ.Methods {
+				if match(method, meth.Name) {
+					syms = append(syms, refSymbol{pkg.oneLineNode(meth.Decl), typ.Name, meth.Name})
+				}
+			}
+		}
+		return syms
+	}
+	for _, fun := range pkg.findFuncs(symbol) {
+		syms = append(syms, refSymbol{pkg.oneLineNode(fun.Decl), fun.Name, ""})
+	}
+	for _, values := range [][]*doc.Value{pkg.doc.Consts, pkg.doc.Vars} {
+		for _, value := range values {
+			for _, name := range value.Names {
+				if match(symbol, name) {
+					decl := pkg.oneLineNode(value.Decl, godoc.WithValueName(name))
+					syms = append(syms, refSymbol{decl, name, ""})
+				}
+			}
+		}
+	}
+	for _, typ := range pkg.findTypes(symbol) {
+		spec := pkg.findTypeSpec(typ.Decl, typ.Name)
+		syms = append(syms, refSymbol{pkg.oneLineNode(spec), typ.Name, ""})
+	}
+	return syms
+}
+
+// printRefs prints the references to the package, or to the symbols matching
+// symbol or symbol.method, made by the packages of the local module or
+// workspace. Each symbol is shown with its number of references, which are
+// then listed by file and line, grouped by package. It reports whether any
+// symbol matched.
+func (pkg *Package) printRefs(symbol, method string) bool {
+	syms := []refSymbol{{}}
+	if symbol != "" {
+		syms = pkg.findRefSymbols(symbol, method)
+		if len(syms) == 0 {
+			return false
+		}
+	}
+
+	roots := localCodeRoots()
+	if len(roots) == 0 {
+		pkg.Fatalf("-refs requires a module or workspace")
+	}
+	found, err := refs.Find(context.Background(), roots, pkg.importPath())
+	if err != nil {
+		pkg.Fatalf("failed to find references: %v", err)
+	}
+
+	pkg.buf.Code()
+	for i, sym := range syms {
+		var symRefs []refs.Ref
+		var numPkgs int
+		for _, ref := range found {
+			if sym.symbol != "" && ref.Symbol != sym.symbol ||
+				sym.method != "" && ref.Method != sym.method {
+				continue
+			}
+			if len(symRefs) == 0 || symRefs[len(symRefs)-1].ImportPath != ref.ImportPath {
+				numPkgs++
+			}
+			symRefs = append(symRefs, ref)
+		}
+
+		if i > 0 {
+			pkg.newlines(2)
+		}
+		header := fmt.Sprintf("// %s in %s", plural(len(symRefs), "reference"), plural(numPkgs, "package"))
+		if sym.decl != "" {
+			header = sym.decl + " " + header
+		}
+		pkg.Printf("%s\n", header)
+		for j, ref := range symRefs {
+			if j == 0 || symRefs[j-1].ImportPath != ref.ImportPath {
+				pkg.Printf("\n%s\n", ref.ImportPath)
+			}
+			pkg.Printf("%s%s:%d\n", indent, workdir.Rel(ref.Filename, subs...), ref.Line)
+		}
+	}
+	return true
+}
+
+// importPath returns the import path of the package, derived from the code
+// roots in the same way as packageClause.
+func (pkg *Package) importPath() string {
+	importPath := pkg.build.ImportPath
+	for _, root := range codeRoots() {
+		if pkg.build.Dir == root.dir {
+			importPath = root.importPath
+			break
+		}
+		if strings.HasPrefix(pkg.build.Dir, root.dir+string(filepath.Separator)) {
+			importPath = path.Join(root.importPath, filepath.ToSlash(pkg.build.Dir[len(root.dir)+1:]))
+			break
+		}
+	}
+	return notRequiredImportPath(pkg.build.Dir, importPath)
+}
+
+// plural returns n followed by noun, which is pluralized unless n is 1.
+func plural(n int, noun string) string {
+	if n == 1 {
+		return "1 " + noun
+	}
+	return fmt.Sprintf("%d %ss", n, noun)
+}
+
+// implementsDoc lists the types which implement typ if it is an interface and
+// the -impls flag is set, or the interfaces which typ implements if it is not
+// an interface and the -implements flag is set.
+func (pkg *Package) implementsDoc(typ *doc.Type) {
+	spec := pkg.findTypeSpec(typ.Decl, typ.Name)
+	_, isInterface := spec.Type.(*ast.InterfaceType)
+	if isInterface && !implements.Impls || !isInterface && !implements.Implements {
+		return
+	}
+
+	loader := implements.NewLoader(dirsToIndexModules(codeRoots()...))
+	typesPkg, err := loader.LoadDir(pkg.importPath(), pkg.build.Dir)
+	if err != nil {
+		pkg.Fatalf("failed to type check package: %v", err)
+	}
+	obj, ok := typesPkg.Scope().Lookup(typ.Name).(*types.TypeName)
+	if !ok {
+		pkg.Fatalf("failed to type check %s", typ.Name)
+	}
+	named, ok := types.Unalias(obj.Type()).(*types.Named)
+	if !ok {
+		return
+	}
+	qualifier := func(other *types.Package) string {
+		if other == typesPkg {
+			return ""
+		}
+		return other.Name()
+	}
+
+	if isInterface {
+		if err := loader.LoadAll(context.Background(), localCodeRoots()); err != nil {
+			pkg.Fatalf("failed to load local packages: %v", err)
+		}
+		if err := loader.LoadDependencies(context.Background(), dependencyCodeRoots()); err != nil {
+			pkg.Fatalf("failed to load dependencies: %v", err)
+		}
+		iface := named.Underlying().(*types.Interface)
+		impls := implements.FindImpls(loader, iface, unexported)
+		pkg.printHeader("IMPLEMENTATIONS")
+		if len(impls) == 0 {
+			pkg.Printf("// none found in the local module or its dependencies\n")
+		}
+		for _, impl := range impls {
+			pkg.Printf("%s // %s +%d\n", types.TypeString(impl.Type, qualifier),
+				workdir.Rel(impl.Pos.Filename, subs...), impl.Pos.Line)
+		}
+		return
+	}
+
+	ifaces := implements.FindInterfaces(loader, named)
+	pkg.printHeader("INTERFACES")
+	if len(ifaces) == 0 {
+		pkg.Printf("// none in scope or well known\n")
+	}
+	for _, iface := range ifaces {
+		name := types.TypeString(iface.Obj.Type(), qualifier)
+		switch {
+		case iface.Missing == nil && iface.PointerOnly:
+			pkg.Printf("%s // implemented by *%s\n", name, typ.Name)
+		case iface.Missing == nil:
+			pkg.Printf("%s\n", name)
+		case iface.WrongType && iface.Have != nil:
+			pkg.Printf("%s // wrong type for method %s: have %s, want %s\n", name, iface.Missing.Name(),
+				methodString(iface.Have, qualifier), methodString(iface.Missing, qualifier))
+		default:
+			pkg.Printf("%s // missing method %s\n", name, methodString(iface.Missing, qualifier))
+		}
+	}
+}
+
+// methodString returns the name and signature of method, without its
+// receiver, e.g. Read(p []byte) (n int, err error).
+func methodString(method *types.Func, qualifier types.Qualifier) string {
+	sig := method.Type().(*types.Signature)
+	sig = types.NewSignatureType(nil, nil, nil, sig.Params(), sig.Results(), sig.Variadic())
+	return method.Name() + strings.TrimPrefix(types.TypeString(sig, qualifier), "func")
+}
@@ -0,0 +1,179 @@
+package main
+
+import (
+	"bytes"
+	"flag"
+	"go/build"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"golang.org/x/mod/modfile"
+
+	"aslevy.com/go-doc/internal/completion"
+	"aslevy.com/go-doc/internal/godoc"
+)
+
+// serving sets up the state of a daemon handling a request until the test
+// ends.
+func serving(t *testing.T, completionRequested bool) {
+	served.serving, completion.Requested = true, completionRequested
+	t.Cleanup(func() {
+		served.serving, completion.Requested, served.packages = false, false, nil
+	})
+}
+
+func TestCachedPackage(t *testing.T) {
+	serving(t, true)
+	dir := t.TempDir()
+	file := filepath.Join(dir, "a.go")
+	writeFile := func(src string, modTime time.Time) {
+		t.Helper()
+		if err := os.WriteFile(file, []byte(src), 0644); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.Chtimes(file, modTime, modTime); err != nil {
+			t.Fatal(err)
+		}
+	}
+	parse := func() *Package {
+		t.Helper()
+		buildPkg, err := build.ImportDir(dir, build.ImportComment)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return parsePackage(io.Discard, buildPkg, "a")
+	}
+	funcs := func(pkg *Package) []string {
+		var names []string
+		for _, fun := range pkg.doc.Funcs {
+			names = append(names, fun.Name)
+		}
+		return names
+	}
+
+	now := time.Now()
+	writeFile("package a\n\nfunc Old() {}\n", now)
+	first := parse()
+	if again := parse(); again.doc != first.doc {
+		t.Errorf("the package was parsed again, although it did not change")
+	}
+
+	writeFile("package a\n\nfunc New() {}\n", now.Add(time.Second))
+	edited := parse()
+	if got := funcs(edited); len(got) != 1 || got[0] != "New" {
+		t.Errorf("funcs after edit = %v, want [New]", got)
+	}
+
+	// Rendering docs edits the AST, so packages are never reused for docs.
+	completion.Requested = false
+	if docs := parse(); docs.doc == edited.doc {
+		t.Errorf("a cached package was used to render docs")
+	}
+}
+
+func TestFatalWhileServing(t *testing.T) {
+	serving(t, false)
+	dir := t.TempDir()
+	// The package clause is valid, so the package is found, but it fails to
+	// parse.
+	if err := os.WriteFile(filepath.Join(dir, "a.go"), []byte("package a\n\nfunc F() {\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	var out bytes.Buffer
+	err := do(&out, flag.NewFlagSet("go doc", flag.ContinueOnError), []string{dir})
+	if err == nil || !strings.Contains(err.Error(), "expected") {
+		t.Errorf("do() = %v, want a parse error", err)
+	}
+	if err != nil && strings.Contains(err.Error(), "did you mean") {
+		t.Errorf("do() = %v, want no suggestions", err)
+	}
+}
+
+// moduleDirs are Dirs which know the module of each package dir.
+type moduleDirs struct {
+	godoc.Dirs
+	modules map[string]godoc.Module
+}
+
+func (d moduleDirs) Module(dir string) (godoc.Module, bool) {
+	mod, ok := d.modules[dir]
+	return mod, ok
+}
+
+func TestModuleHeader(t *testing.T) {
+	const goMod = `module example.com/main
+
+go 1.22
+
+require (
+	example.com/dep v1.2.0
+	example.com/fork v1.0.0
+	example.com/vendored v0.2.0
+	example.com/work v1.0.0
+)
+
+replace example.com/fork => ../fork
+`
+	f, err := modfile.Parse("go.mod", []byte(goMod), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func(showModule bool, dirs godoc.Dirs) {
+		godoc.ShowModule, xdirs = showModule, dirs
+		resetCodeRoots()
+	}(godoc.ShowModule, xdirs)
+	godoc.ShowModule = true
+	mainGoMod = func() *modfile.File { return f }
+	workspaceModules = func() map[string]bool { return map[string]bool{"example.com/work": true} }
+
+	for _, test := range []struct {
+		name string
+		mod  godoc.Module
+		want string
+	}{{
+		name: "stdlib",
+		mod:  godoc.Module{Path: "std", Version: "go1.22.0", Class: "stdlib"},
+		want: "// module std go1.22.0 (stdlib)\n",
+	}, {
+		name: "main module",
+		mod:  godoc.Module{Path: "example.com/main", Class: "local"},
+		want: "// module example.com/main (local)\n",
+	}, {
+		// go.work replaces the requirement of the main module.
+		name: "workspace module",
+		mod:  godoc.Module{Path: "example.com/work", Class: "local"},
+		want: "// module example.com/work (local)\n",
+	}, {
+		name: "required",
+		mod:  godoc.Module{Path: "example.com/dep", Version: "v1.2.0", Class: "required"},
+		want: "// module example.com/dep v1.2.0 (required)\n",
+	}, {
+		// The directory of the replacement does not tell the version.
+		name: "replaced by a directory",
+		mod:  godoc.Module{Path: "example.com/fork", Class: "local"},
+		want: "// module example.com/fork v1.0.0 => ../fork (required)\n",
+	}, {
+		name: "vendored",
+		mod:  godoc.Module{Path: "example.com/vendored", Version: "v0.1.0", Class: "vendored"},
+		want: "// module example.com/vendored v0.1.0 (vendored)\n",
+	}, {
+		name: "not required",
+		mod:  godoc.Module{Path: "example.com/other", Version: "v3.0.0", Class: "not required"},
+		want: "// module example.com/other v3.0.0 (not required)\n",
+	}} {
+		t.Run(test.name, func(t *testing.T) {
+			dir := filepath.Join("/src", test.mod.Path)
+			xdirs = moduleDirs{modules: map[string]godoc.Module{dir: test.mod}}
+			pkg := &Package{build: &build.Package{Dir: dir}}
+			pkg.buf.pkg, pkg.buf.printed = pkg, true
+			pkg.moduleHeader()
+			if got := pkg.buf.String(); got != test.want {
+				t.Errorf("moduleHeader() = %q, want %q", got, test.want)
+			}
+		})
+	}
+}